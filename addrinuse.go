@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package profiler
+
+import "syscall"
+
+// errAddrInUse is the error of a bind to an address which is already in use
+const errAddrInUse = syscall.EADDRINUSE
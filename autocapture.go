@@ -0,0 +1,133 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"time"
+)
+
+// autoCapture is the configuration of WithAutoCapture
+type autoCapture struct {
+	kind     string
+	delay    time.Duration
+	duration time.Duration
+	dir      string
+}
+
+// WithAutoCapture captures the profile kind (ProfileCPU or the name of a runtime/pprof profile like "heap"
+// or "goroutine") delay after the pprof endpoint opened and writes it to a file in dir or to the storage set
+// with WithProfileStorage, the name of the profile is reported by an InfoEvent. The CPU profile is recorded
+// for duration, it waits for a CPU profile requested by HTTP to finish. The capture is canceled when the
+// pprof endpoint is shutdown. New ignores the option with an ErrorEvent if the kind is not known,
+// NewWithError returns ErrUnknownProfile.
+func WithAutoCapture(kind string, delay, duration time.Duration, dir string) Opt {
+	return func(p *Profiler) {
+		p.autoCapture = &autoCapture{kind: kind, delay: delay, duration: duration, dir: dir}
+	}
+}
+
+// checkAutoCapture returns ErrUnknownProfile if the kind of the auto capture is not known
+func (p *Profiler) checkAutoCapture() error {
+	if p.autoCapture == nil || p.autoCapture.kind == ProfileCPU || pprof.Lookup(p.autoCapture.kind) != nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknownProfile, p.autoCapture.kind)
+}
+
+// startAutoCapture starts the auto capture for the activation, the returned function cancels it
+// and waits until it is finished
+func (p *Profiler) startAutoCapture(ctx context.Context) func() {
+	if p.autoCapture == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	open := p.WindowOpen()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		p.runAutoCapture(ctx, open)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runAutoCapture captures the profile after the window opened until the context is done
+func (p *Profiler) runAutoCapture(ctx context.Context, open <-chan struct{}) {
+	select {
+	case <-open:
+	case <-ctx.Done():
+		return
+	}
+
+	timer := p.clock.NewTimer(p.autoCapture.delay)
+
+	select {
+	case <-timer.C():
+	case <-ctx.Done():
+		timer.Stop()
+		return
+	}
+
+	name, err := p.captureToStorage(ctx)
+	if err != nil {
+		p.evt(WarnEvent, CodeProfileCaptureFailed, MsgProfileCaptureFailed, "kind", p.autoCapture.kind, "error", err)
+		return
+	}
+
+	p.evt(InfoEvent, CodeProfileCaptured, MsgProfileCaptured, "kind", p.autoCapture.kind, "name", name)
+}
+
+// captureToStorage captures the profile of the auto capture and returns the name of the stored profile
+func (p *Profiler) captureToStorage(ctx context.Context) (string, error) {
+	c := p.autoCapture
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if c.kind == ProfileCPU {
+		if !waitCPUProfile(ctx) {
+			return "", ctx.Err()
+		}
+
+		data, err = p.recordCPU(ctx, c.duration)
+
+		releaseCPUProfile()
+	} else {
+		profile := pprof.Lookup(c.kind)
+		if profile == nil {
+			return "", fmt.Errorf("%w %q", ErrUnknownProfile, c.kind)
+		}
+
+		var buf bytes.Buffer
+
+		err = profile.WriteTo(&buf, 0)
+		data = buf.Bytes()
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	name := c.kind
+	if len(p.captureMetadata) > 0 {
+		if data, err = addComments(data, p.metadataComments()); err != nil {
+			return "", err
+		}
+
+		name += "-" + p.metadataName()
+	}
+
+	name = fmt.Sprintf("%s-%s.pprof", name, p.clock.Now().UTC().Format("20060102T150405.000"))
+
+	return name, p.profileStorage(c.dir).Store(name, bytes.NewReader(data))
+}
@@ -0,0 +1,108 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAutoCapture(t *testing.T) {
+	_, err := NewWithError(WithAutoCapture("unknown", 0, 0, ""))
+	assert.True(t, errors.Is(err, ErrUnknownProfile))
+
+	// New ignores an unknown kind
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle), WithAutoCapture("unknown", 0, 0, ""))
+	assert.Nil(t, p.autoCapture)
+	assert.Equal(t, 1, r.count(MsgInvalidOption))
+	assert.True(t, errors.Is(p.checkConfig(), ErrUnknownProfile))
+
+	// the profile is looked up again at capture time
+	p.autoCapture = &autoCapture{kind: "unknown"}
+	_, err = p.captureToStorage(context.Background())
+	assert.True(t, errors.Is(err, ErrUnknownProfile))
+
+	for _, kind := range []string{ProfileCPU, "goroutine"} {
+		kind := kind
+
+		t.Run(kind, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "profiler")
+			require.NoError(t, err)
+
+			defer os.RemoveAll(dir)
+
+			names := make(chan string, 1)
+			p := New(
+				WithSignal(testSignal),
+				WithAddress(freeAddress(t)),
+				WithAutoCapture(kind, 10*time.Millisecond, 50*time.Millisecond, dir),
+				WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+					if code == CodeProfileCaptured {
+						names <- args[3].(string)
+					}
+				}),
+			)
+
+			// an HTTP initiated CPU profile is in progress
+			require.True(t, acquireCPUProfile())
+
+			p.Start()
+			<-p.Started()
+			assert.NoError(t, p.Trigger(context.Background()))
+
+			if kind == ProfileCPU {
+				// the CPU profile waits for the profile in progress
+				select {
+				case <-names:
+					t.Fatal("CPU profile captured concurrently")
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+
+			releaseCPUProfile()
+
+			name := <-names
+			p.Stop()
+
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err)
+			assert.Equal(t, gzipMagic, data[:2])
+		})
+	}
+}
+
+func TestWithProfileStorage(t *testing.T) {
+	stored := make(chan []byte, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		// the directory is not used with a profile storage
+		WithAutoCapture("goroutine", 0, 0, "/nonexistent"),
+		WithProfileStorage(ProfileStorageFunc(func(name string, r io.Reader) error {
+			data, err := ioutil.ReadAll(r)
+			if err == nil && strings.HasPrefix(name, "goroutine-") {
+				stored <- data
+			}
+
+			return err
+		})),
+	)
+
+	p.Start()
+	<-p.Started()
+	assert.NoError(t, p.Trigger(context.Background()))
+
+	data := <-stored
+	p.Stop()
+
+	assert.Equal(t, gzipMagic, data[:2])
+}
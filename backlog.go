@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package profiler
+
+import "syscall"
+
+// listenSocket sets the backlog of the listening socket
+func listenSocket(fd uintptr, backlog int) error {
+	return syscall.Listen(int(fd), backlog)
+}
@@ -0,0 +1,81 @@
+package profiler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+// maxBufferedProfile is the maximum size of a profile buffered by WithBufferedProfiles
+const maxBufferedProfile = 64 << 20
+
+// errBufferFull is returned to the handler if the profile exceeds the buffer
+var errBufferFull = errors.New("profile exceeds the buffer") // nolint: gochecknoglobals
+
+// WithBufferedProfiles buffers the profiles in memory before they are sent to the client, the capture
+// (which holds runtime resources, e.g. the CPU profiler) completes independent of the speed of the client.
+// A profile larger than 64 MiB is answered with 503 Service Unavailable.
+func WithBufferedProfiles(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.bufferProfiles = enabled
+	}
+}
+
+// bufferProfiles writes the response of the profile routes to the client after the handler returned
+func bufferProfiles(max int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !profileRoute(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferWriter{header: http.Header{}, status: http.StatusOK, max: max}
+		next.ServeHTTP(bw, r)
+
+		if bw.full {
+			serveError(w, http.StatusServiceUnavailable, errBufferFull.Error())
+			return
+		}
+
+		for k, v := range bw.header {
+			w.Header()[k] = v
+		}
+
+		w.WriteHeader(bw.status)
+		_, _ = w.Write(bw.buf.Bytes())
+	})
+}
+
+// bufferWriter buffers the response up to max bytes
+type bufferWriter struct {
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	max         int
+	full        bool
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+
+	if w.full || w.buf.Len()+len(b) > w.max {
+		w.full = true
+		w.buf.Reset()
+
+		return 0, errBufferFull
+	}
+
+	return w.buf.Write(b)
+}
@@ -0,0 +1,42 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithBufferedProfiles(t *testing.T) {
+	p := New(WithBufferedProfiles(true))
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "goroutine profile:")
+}
+
+func TestBufferProfilesLimit(t *testing.T) {
+	h := bufferProfiles(10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(strings.Repeat("x", 8)))
+		_, _ = w.Write([]byte(strings.Repeat("x", 8)))
+	}))
+
+	// the profile exceeds the buffer
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, errBufferFull.Error()+"\n", rec.Body.String())
+
+	// other routes are not buffered
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/app", nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, strings.Repeat("x", 16), rec.Body.String())
+}
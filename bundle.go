@@ -0,0 +1,137 @@
+package profiler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+const (
+	// bundlePath is the route serving the profile bundle
+	bundlePath = "/debug/bundle"
+	// bundleCPUSeconds is the default duration of the CPU profile within the bundle
+	bundleCPUSeconds = 10
+)
+
+// WithBundle enables the /debug/bundle route which collects a cpu, heap and goroutine
+// profile together with the runtime memstats and returns them as a zip archive
+//
+// The duration of the CPU profile can be set with the seconds query parameter (default: 10s).
+func WithBundle(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.bundle = enabled
+	}
+}
+
+// serveBundle collects the profile bundle and streams it as zip archive
+func (p *Profiler) serveBundle(w http.ResponseWriter, r *http.Request) {
+	sec, err := strconv.ParseInt(r.FormValue("seconds"), 10, 64)
+	if sec <= 0 || err != nil {
+		sec = bundleCPUSeconds
+	}
+
+	d := time.Duration(sec) * time.Second
+
+	if durationExceedsWriteTimeout(r, d) {
+		serveError(w, http.StatusBadRequest, "profile duration exceeds server's WriteTimeout")
+		return
+	}
+
+	// the CPU profile is collected first, so errors can still be reported with a proper status code
+	if !acquireCPUProfile() {
+		serveError(w, http.StatusConflict, ErrCPUProfileInProgress.Error())
+		return
+	}
+
+	cpu := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		releaseCPUProfile()
+		serveError(w, http.StatusInternalServerError, fmt.Sprintf("could not enable CPU profiling: %s", err))
+
+		return
+	}
+
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+
+	pprof.StopCPUProfile()
+	releaseCPUProfile()
+
+	if r.Context().Err() != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile-bundle.zip"`)
+
+	zw := zip.NewWriter(w)
+
+	if err := writeBundle(zw, cpu.Bytes()); err != nil {
+		p.evt(ErrorEvent, CodeBundleFailed, MsgBundleFailed, "error", err)
+		return
+	}
+
+	if err := zw.Close(); err != nil {
+		p.evt(ErrorEvent, CodeBundleFailed, MsgBundleFailed, "error", err)
+	}
+}
+
+// writeBundle adds the bundle entries to the zip archive
+func writeBundle(zw *zip.Writer, cpu []byte) error {
+	f, err := zw.Create("cpu.pprof")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(cpu); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"heap", "goroutine"} {
+		f, err := zw.Create(name + ".pprof")
+		if err != nil {
+			return err
+		}
+
+		if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+			return err
+		}
+	}
+
+	var m runtime.MemStats
+
+	runtime.ReadMemStats(&m)
+
+	f, err = zw.Create("memstats.json")
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(m)
+}
+
+// durationExceedsWriteTimeout reports whether the profile duration exceeds the WriteTimeout of the server
+func durationExceedsWriteTimeout(r *http.Request, d time.Duration) bool {
+	srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+	return ok && srv.WriteTimeout != 0 && d >= srv.WriteTimeout
+}
+
+// serveError replies with a plain text error message
+func serveError(w http.ResponseWriter, status int, txt string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Go-Pprof", "1")
+	w.Header().Del("Content-Disposition")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, txt)
+}
@@ -0,0 +1,48 @@
+package profiler
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBundle(t *testing.T) {
+	p := New(WithBundle(true))
+	assert.True(t, p.bundle)
+}
+
+func TestBundle(t *testing.T) {
+	p := New(WithBundle(true))
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/bundle?seconds=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+
+	body, err := ioutil.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+
+	assert.Equal(t, []string{"cpu.pprof", "heap.pprof", "goroutine.pprof", "memstats.json"}, names)
+}
+
+func TestBundleDisabled(t *testing.T) {
+	p := New()
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/bundle", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,63 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"time"
+)
+
+// CaptureCPU records a CPU profile for the duration d and returns it in the pprof format
+//
+// The profile is stopped early if the context is done, the context error is returned in that case.
+// Only one CPU profile can be active at a time, ErrCPUProfileInProgress is returned if another
+// CPU profile of the package is in progress.
+func (p *Profiler) CaptureCPU(ctx context.Context, d time.Duration) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !acquireCPUProfile() {
+		return nil, ErrCPUProfileInProgress
+	}
+
+	defer releaseCPUProfile()
+
+	return p.recordCPU(ctx, d)
+}
+
+// recordCPU records a CPU profile like CaptureCPU, the caller has to acquire the CPU profile
+func (p *Profiler) recordCPU(ctx context.Context, d time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return nil, err
+	}
+
+	timer := p.clock.NewTimer(d)
+
+	select {
+	case <-timer.C():
+	case <-ctx.Done():
+		timer.Stop()
+	}
+
+	pprof.StopCPUProfile()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CaptureHeap returns a heap profile in the pprof format
+func (p *Profiler) CaptureHeap() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := pprof.WriteHeapProfile(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,43 @@
+package profiler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gzipMagic are the first bytes of a profile in the pprof format
+var gzipMagic = []byte{0x1f, 0x8b} // nolint: gochecknoglobals
+
+func TestCaptureCPU(t *testing.T) {
+	p := New()
+
+	b, err := p.CaptureCPU(context.Background(), 100*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, gzipMagic, b[:2])
+}
+
+func TestCaptureCPUCanceled(t *testing.T) {
+	c := newFakeClock()
+	p := New(WithClock(c))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-c.timers
+		cancel()
+	}()
+
+	_, err := p.CaptureCPU(ctx, time.Hour)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCaptureHeap(t *testing.T) {
+	p := New()
+
+	b, err := p.CaptureHeap()
+	require.NoError(t, err)
+	assert.Equal(t, gzipMagic, b[:2])
+}
@@ -0,0 +1,46 @@
+package profiler
+
+import "time"
+
+// Clock provides the time functions used by the Profiler
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// NewTimer creates a new Timer that will send the current time on its channel after at least duration d
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer represents a single event timer created by a Clock
+type Timer interface {
+	// C returns the channel on which the time is delivered
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing, see time.Timer.Stop
+	Stop() bool
+}
+
+// WithClock sets the clock used for the timeout of the pprof endpoint
+func WithClock(c Clock) Opt {
+	return func(p *Profiler) {
+		p.clock = c
+	}
+}
+
+// realClock implements Clock with the functions of the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer implements Timer with a time.Timer
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
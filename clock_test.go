@@ -0,0 +1,99 @@
+package profiler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	timers chan *fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		timers: make(chan *fakeTimer, 1),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return time.Time{}
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{
+		c: make(chan time.Time, 1),
+	}
+	c.timers <- t
+
+	return t
+}
+
+type fakeTimer struct {
+	c chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return true
+}
+
+// fire expires the timer
+func (t *fakeTimer) fire() {
+	t.c <- time.Time{}
+}
+
+type shutdownHook chan struct{}
+
+func (shutdownHook) PreStart() {}
+
+func (h shutdownHook) PostShutdown() {
+	h <- struct{}{}
+}
+
+func TestWithClock(t *testing.T) {
+	c := newFakeClock()
+	p := New(WithClock(c))
+	assert.Equal(t, c, p.clock)
+}
+
+func TestTimeoutWithClock(t *testing.T) {
+	for name, activate := range activations {
+		activate := activate
+
+		t.Run(name, func(t *testing.T) {
+			address := freeAddress(t)
+			c := newFakeClock()
+			hook := make(shutdownHook, 1)
+			p := New(
+				WithSignal(testSignal),
+				WithAddress(address),
+				WithClock(c),
+				WithHooks(hook),
+			)
+
+			timer := openWindowWith(t, p, c, activate)
+
+			// the window stays open until the timer fires
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			_ = resp.Body.Close()
+
+			timer.fire()
+			<-hook
+
+			_, err = http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+			assert.Error(t, err)
+
+			p.Stop()
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package profiler
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// WithCmdline sets the command line served on /debug/pprof/cmdline and as cmdline variable on /debug/vars
+// instead of os.Args, e.g. to hide secrets passed as arguments.
+func WithCmdline(args []string) Opt {
+	return func(p *Profiler) {
+		p.cmdline = append([]string{}, args...)
+	}
+}
+
+// WithCmdlineRedactor sets a function to strip or mask arguments (e.g. --db-password=...) of the command line
+// before it is served on /debug/pprof/cmdline and /debug/vars. The function receives a copy of the arguments.
+func WithCmdlineRedactor(f func([]string) []string) Opt {
+	return func(p *Profiler) {
+		p.cmdlineRedactor = f
+	}
+}
+
+// cmdlineArgs returns the command line served by the pprof endpoint
+func (p *Profiler) cmdlineArgs() []string {
+	args := os.Args
+	if p.cmdline != nil {
+		args = p.cmdline
+	}
+
+	if p.cmdlineRedactor != nil {
+		return p.cmdlineRedactor(append([]string{}, args...))
+	}
+
+	return args
+}
+
+// serveCmdline serves the command line, see net/http/pprof.Cmdline
+func (p *Profiler) serveCmdline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, strings.Join(p.cmdlineArgs(), "\x00"))
+}
+
+// serveVars serves the expvar variables like expvar.Handler,
+// the cmdline variable is replaced by the command line of the Profiler
+func (p *Profiler) serveVars(w http.ResponseWriter, r *http.Request) {
+	cmdline, err := json.Marshal(p.cmdlineArgs())
+	if err != nil {
+		serveError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+
+	first := true
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+
+		first = false
+
+		if kv.Key == "cmdline" {
+			fmt.Fprintf(w, "%q: %s", kv.Key, cmdline)
+			return
+		}
+
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+
+	fmt.Fprintf(w, "\n}\n")
+}
@@ -0,0 +1,65 @@
+package profiler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCmdline(t *testing.T) {
+	args := []string{"app", "--verbose"}
+	p := New(WithCmdline(args))
+	assert.Equal(t, args, p.cmdline)
+	assert.Equal(t, args, p.cmdlineArgs())
+	assert.Equal(t, os.Args, New().cmdlineArgs())
+}
+
+func TestCmdline(t *testing.T) {
+	args := []string{"app", "--verbose"}
+	h := New(WithCmdline(args)).mux()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, strings.Join(args, "\x00"), rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	vars := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	assert.Equal(t, []interface{}{"app", "--verbose"}, vars["cmdline"])
+}
+
+func TestWithCmdlineRedactor(t *testing.T) {
+	args := []string{"app", "--db-password=secret", "--verbose"}
+	redact := func(args []string) []string {
+		for i, a := range args {
+			if strings.HasPrefix(a, "--db-password=") {
+				args[i] = "--db-password=***"
+			}
+		}
+
+		return args
+	}
+
+	p := New(WithCmdline(args), WithCmdlineRedactor(redact))
+	assert.Equal(t, []string{"app", "--db-password=***", "--verbose"}, p.cmdlineArgs())
+	// the configured arguments are not modified
+	assert.Equal(t, "--db-password=secret", p.cmdline[1])
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	assert.NotContains(t, rec.Body.String(), "secret")
+
+	rec = httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.NotContains(t, rec.Body.String(), "secret")
+}
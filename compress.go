@@ -0,0 +1,199 @@
+package profiler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Encoder returns a writer compressing to w, e.g. for zstd:
+//
+//	func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+// encoder is a named Encoder
+type encoder struct {
+	name string
+	f    Encoder
+}
+
+// WithCompression compresses the responses of the pprof endpoint with gzip if accepted by the client
+// (see WithEncoder for additional encodings). Responses which are already compressed (e.g. the binary
+// pprof profiles and the profile bundle) are not compressed again.
+func WithCompression(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.compression = enabled
+	}
+}
+
+// WithEncoder registers an additional content encoding (e.g. "zstd") for the compression of the responses,
+// see WithCompression. The registered encodings are preferred over gzip, the last registered encoding first.
+func WithEncoder(name string, f Encoder) Opt {
+	return func(p *Profiler) {
+		p.compression = true
+		p.encoders = append([]encoder{{name: strings.ToLower(name), f: f}}, p.encoders...)
+	}
+}
+
+// compress compresses the responses with the preferred encoding accepted by the client
+func (p *Profiler) compress(next http.Handler) http.Handler {
+	encoders := append(append([]encoder{}, p.encoders...), encoder{name: "gzip", f: newGzipWriter})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		enc, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), encoders)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoder: enc}
+
+		defer func() {
+			if err := cw.Close(); err != nil {
+				p.evt(WarnEvent, CodeCompressFailed, MsgCompressFailed, "path", r.URL.Path, "error", err)
+			}
+		}()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// newGzipWriter is the Encoder for gzip
+func newGzipWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// negotiateEncoding returns the first encoder accepted by the Accept-Encoding header
+func negotiateEncoding(header string, encoders []encoder) (encoder, bool) {
+	accepted := map[string]bool{}
+
+	for _, token := range strings.Split(header, ",") {
+		parts := strings.Split(token, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		accepted[name] = true
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil && q == 0 {
+				accepted[name] = false
+			}
+		}
+	}
+
+	for _, e := range encoders {
+		if accepted[e.name] {
+			return e, true
+		}
+	}
+
+	return encoder{}, false
+}
+
+// compressedTypes are the content types of responses which are already compressed
+// nolint: gochecknoglobals
+var compressedTypes = []string{"application/octet-stream", "application/zip", "application/gzip", "application/x-gzip"}
+
+// compressWriter compresses the response unless it is already compressed
+type compressWriter struct {
+	http.ResponseWriter
+	encoder encoder
+	enc     io.WriteCloser
+	decided bool
+	err     error
+}
+
+// decide enables the compression depending on the headers of the response
+func (w *compressWriter) decide(status int) {
+	if w.decided {
+		return
+	}
+
+	w.decided = true
+
+	h := w.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified || h.Get("Content-Encoding") != "" {
+		return
+	}
+
+	ct := h.Get("Content-Type")
+	for _, t := range compressedTypes {
+		if strings.HasPrefix(ct, t) {
+			return
+		}
+	}
+
+	enc, err := w.encoder.f(w.ResponseWriter)
+	if err != nil {
+		w.err = err
+		return
+	}
+
+	w.enc = enc
+
+	h.Set("Content-Encoding", w.encoder.name)
+	h.Del("Content-Length")
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	w.decide(status)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+
+		w.decide(http.StatusOK)
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer does, a flush before the first write
+// sends the headers, therefore the compression is decided first
+func (w *compressWriter) Flush() {
+	w.decide(http.StatusOK)
+
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// Push implements http.Pusher if the underlying writer does
+func (w *compressWriter) Push(target string, opts *http.PushOptions) error {
+	return push(w.ResponseWriter, target, opts)
+}
+
+// Close flushes the compressed response
+func (w *compressWriter) Close() error {
+	if w.enc != nil {
+		return w.enc.Close()
+	}
+
+	return w.err
+}
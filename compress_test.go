@@ -0,0 +1,108 @@
+package profiler
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCompression(t *testing.T) {
+	h := New(WithCompression(true)).mux()
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "goroutine profile:")
+
+	// binary profiles are already compressed
+	req = httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, gzipMagic, rec.Body.Bytes()[:2])
+
+	// the encoding is not accepted
+	req = httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Contains(t, rec.Body.String(), "goroutine profile:")
+}
+
+func TestWithEncoder(t *testing.T) {
+	h := New(WithEncoder("deflate", func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestCompression)
+	})).mux()
+
+	// the registered encoding is preferred over gzip
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+
+	b, err := ioutil.ReadAll(flate.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "goroutine profile:")
+
+	// fallback to gzip
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+}
+
+func TestCompressionFlushBeforeWrite(t *testing.T) {
+	h := New(WithCompression(true), WithExtraHandler("/debug/events", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("data: hello\n\n"))
+	}))).mux()
+
+	// the recorder does not freeze the headers on a flush, a server does
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/debug/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "data: hello\n\n", string(b))
+}
@@ -0,0 +1,53 @@
+package profiler
+
+import "strings"
+
+// WithLogConfigOnInit emits an InfoEvent with the effective configuration (signal, address, timeout
+// and the enabled features) when the Profiler is created by New
+func WithLogConfigOnInit(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.logConfigOnInit = enabled
+	}
+}
+
+// logConfig emits the effective configuration
+func (p *Profiler) logConfig() {
+	p.evt(InfoEvent, CodeConfigured, MsgConfigured,
+		"signal", signalName(p.signal),
+		"address", p.Address(),
+		"network", p.listenNetwork(),
+		"timeout", p.timeout,
+		"features", strings.Join(p.features(), ","),
+	)
+}
+
+// features returns the names of the enabled optional features
+func (p *Profiler) features() []string {
+	enabled := []struct {
+		name string
+		on   bool
+	}{
+		{"tls", p.server != nil && p.server.TLSConfig != nil},
+		{"auth", p.authFunc != nil},
+		{"cors", len(p.corsOrigins) > 0},
+		{"accesslog", p.accessLog},
+		{"compression", p.compression},
+		{"recover", p.recover},
+		{"autotrigger", p.autoTriggerCond != nil},
+		{"continuous", p.continuous.Sink != nil},
+		{"snapshots", p.snapshots != nil},
+		{"webhook", p.webhookURL != ""},
+		{"signalonce", p.signalOnce},
+		{"muxfactory", p.muxFactory != nil},
+	}
+
+	var names []string
+
+	for _, f := range enabled {
+		if f.on {
+			names = append(names, f.name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,39 @@
+package profiler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogConfigOnInit(t *testing.T) {
+	var args []interface{}
+
+	handler := func(_ EventType, code EventCode, _ string, a ...interface{}) {
+		if code == CodeConfigured {
+			args = a
+		}
+	}
+
+	_ = New(WithEventCodeHandler(handler))
+	assert.Nil(t, args, "disabled by default")
+
+	_ = New(
+		WithEventCodeHandler(handler),
+		WithLogConfigOnInit(true),
+		WithSignal(testSignal),
+		WithAddress("localhost:6061"),
+		WithTimeout(time.Minute),
+		WithAuthFunc(func(*http.Request) bool { return true }),
+		WithAccessLog(true),
+	)
+	assert.Equal(t, []interface{}{
+		"signal", signalName(testSignal),
+		"address", "localhost:6061",
+		"network", "tcp",
+		"timeout", time.Minute,
+		"features", "auth,accesslog",
+	}, args)
+}
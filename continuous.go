@@ -0,0 +1,102 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Profile kinds passed to a ProfileSink
+const (
+	ProfileCPU  = "cpu"
+	ProfileHeap = "heap"
+)
+
+// ProfileSink receives the profiles captured by the continuous profiling, e.g. to push them
+// to a continuous profiling backend or a blob storage. The data is in the pprof format.
+type ProfileSink interface {
+	Push(kind string, data []byte) error
+}
+
+// ProfileSinkFunc is an adapter to use a function as ProfileSink
+type ProfileSinkFunc func(kind string, data []byte) error
+
+// Push calls f(kind, data)
+func (f ProfileSinkFunc) Push(kind string, data []byte) error {
+	return f(kind, data)
+}
+
+// ContinuousConfig configures the continuous profiling
+type ContinuousConfig struct {
+	// Interval between the captures
+	Interval time.Duration
+	// CPUDuration is the duration of the CPU profile, no CPU profile is captured if zero
+	CPUDuration time.Duration
+	// Heap enables the capture of a heap profile
+	Heap bool
+	// Sink receives the captured profiles
+	Sink ProfileSink
+}
+
+// WithContinuousProfiling periodically captures profiles and pushes them to the sink of the configuration
+// while the signal handler is running, independent of the pprof endpoint.
+// Failed captures and pushes are reported as events. New ignores the option with an ErrorEvent if the interval
+// is not positive, NewWithError returns ErrInvalidInterval.
+func WithContinuousProfiling(cfg ContinuousConfig) Opt {
+	return func(p *Profiler) {
+		p.continuous = cfg
+	}
+}
+
+// checkContinuous returns ErrInvalidInterval if the interval of the continuous profiling is not positive
+func (p *Profiler) checkContinuous() error {
+	if p.continuous.Sink == nil || p.continuous.Interval > 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: continuous profiling interval %s", ErrInvalidInterval, p.continuous.Interval)
+}
+
+// continuousProfiling captures and pushes the profiles until the context is done
+func (p *Profiler) continuousProfiling(ctx context.Context) {
+	for {
+		timer := p.clock.NewTimer(p.continuous.Interval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if p.continuous.CPUDuration > 0 {
+			data, err := p.CaptureCPU(ctx, p.continuous.CPUDuration)
+
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				p.evt(WarnEvent, CodeProfileCaptureFailed, MsgProfileCaptureFailed, "kind", ProfileCPU, "error", err)
+			default:
+				p.push(ProfileCPU, data)
+			}
+		}
+
+		if p.continuous.Heap {
+			data, err := p.CaptureHeap()
+			if err != nil {
+				p.evt(WarnEvent, CodeProfileCaptureFailed, MsgProfileCaptureFailed, "kind", ProfileHeap, "error", err)
+				continue
+			}
+
+			p.push(ProfileHeap, data)
+		}
+	}
+}
+
+// push hands the profile to the sink
+func (p *Profiler) push(kind string, data []byte) {
+	if err := p.continuous.Sink.Push(kind, data); err != nil {
+		p.evt(WarnEvent, CodeProfilePushFailed, MsgProfilePushFailed, "kind", kind, "error", err)
+	}
+}
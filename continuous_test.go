@@ -0,0 +1,71 @@
+package profiler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContinuousProfiling(t *testing.T) {
+	kinds := make(chan string, 10)
+	p := New(WithContinuousProfiling(ContinuousConfig{
+		Interval:    10 * time.Millisecond,
+		CPUDuration: 10 * time.Millisecond,
+		Heap:        true,
+		Sink: ProfileSinkFunc(func(kind string, data []byte) error {
+			assert.Equal(t, gzipMagic, data[:2])
+			select {
+			case kinds <- kind:
+			default:
+			}
+
+			return nil
+		}),
+	}))
+
+	p.Start()
+	assert.Equal(t, ProfileCPU, <-kinds)
+	assert.Equal(t, ProfileHeap, <-kinds)
+	p.Stop()
+}
+
+func TestContinuousProfilingPushFailed(t *testing.T) {
+	r := &eventRecorder{}
+	failed := make(chan struct{}, 1)
+	p := New(
+		WithEventHandler(r.handle),
+		WithContinuousProfiling(ContinuousConfig{
+			Interval: 10 * time.Millisecond,
+			Heap:     true,
+			Sink: ProfileSinkFunc(func(string, []byte) error {
+				select {
+				case failed <- struct{}{}:
+				default:
+				}
+
+				return errors.New("backend unavailable")
+			}),
+		}),
+	)
+
+	p.Start()
+	<-failed
+	p.Stop()
+
+	assert.GreaterOrEqual(t, r.count(MsgProfilePushFailed), 1)
+}
+
+func TestContinuousProfilingInterval(t *testing.T) {
+	cfg := ContinuousConfig{Heap: true, Sink: ProfileSinkFunc(func(string, []byte) error { return nil })}
+
+	_, err := NewWithError(WithContinuousProfiling(cfg))
+	assert.True(t, errors.Is(err, ErrInvalidInterval))
+
+	// New ignores the continuous profiling
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle), WithContinuousProfiling(cfg))
+	assert.Nil(t, p.continuous.Sink)
+	assert.Equal(t, 1, r.count(MsgInvalidOption))
+}
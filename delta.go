@@ -0,0 +1,54 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+)
+
+// deltaProfiles are the profiles served by deltaProfile
+// nolint: gochecknoglobals
+var deltaProfiles = []string{"heap", "allocs"}
+
+// deltaProfile returns a handler for the profile which honors the seconds query parameter.
+// With seconds, the handler takes a snapshot, waits and returns the difference to a second snapshot.
+// Durations exceeding the remaining time of the window are rejected.
+func (p *Profiler) deltaProfile(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("seconds") == "" {
+			serveProfile(w, r, name)
+			return
+		}
+
+		sec, err := strconv.ParseInt(r.FormValue("seconds"), 10, 64)
+		if err != nil || sec <= 0 {
+			serveError(w, http.StatusBadRequest, `invalid value for "seconds" - must be a positive integer`)
+			return
+		}
+
+		if d := time.Duration(sec) * time.Second; d > p.windowRemaining() {
+			serveError(w, http.StatusBadRequest, "profile duration exceeds the remaining time of the pprof endpoint")
+			return
+		}
+
+		serveDeltaProfile(w, r, name)
+	})
+}
+
+// windowRemaining returns the remaining time until the window is closed by the timeout
+func (p *Profiler) windowRemaining() time.Duration {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.windowDeadline.IsZero() {
+		return p.timeout
+	}
+
+	return p.windowDeadline.Sub(p.clock.Now())
+}
+
+// serveProfile serves the profile
+func serveProfile(w http.ResponseWriter, r *http.Request, name string) {
+	pprof.Handler(name).ServeHTTP(w, r)
+}
@@ -0,0 +1,11 @@
+//go:build !go1.16
+// +build !go1.16
+
+package profiler
+
+import "net/http"
+
+// serveDeltaProfile rejects the request, net/http/pprof supports delta profiles only since go1.16
+func serveDeltaProfile(w http.ResponseWriter, r *http.Request, name string) {
+	serveError(w, http.StatusNotImplemented, "delta profiles require go1.16 or later")
+}
@@ -0,0 +1,14 @@
+//go:build go1.16
+// +build go1.16
+
+package profiler
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// serveDeltaProfile serves the delta profile, net/http/pprof supports delta profiles since go1.16
+func serveDeltaProfile(w http.ResponseWriter, r *http.Request, name string) {
+	pprof.Handler(name).ServeHTTP(w, r)
+}
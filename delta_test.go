@@ -0,0 +1,46 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeltaProfile(t *testing.T) {
+	c := newFakeClock()
+	p := New(WithClock(c), WithTimeout(time.Minute))
+	h := p.mux()
+
+	tt := []struct {
+		path string
+		code int
+	}{
+		{"/debug/pprof/heap", http.StatusOK},
+		{"/debug/pprof/allocs?seconds=1", http.StatusOK},
+		{"/debug/pprof/heap?seconds=1", http.StatusOK},
+		{"/debug/pprof/heap?seconds=-1", http.StatusBadRequest},
+		{"/debug/pprof/heap?seconds=abc", http.StatusBadRequest},
+		{"/debug/pprof/heap?seconds=120", http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		assert.Equal(t, tc.code, rec.Code, tc.path)
+	}
+}
+
+func TestWindowRemaining(t *testing.T) {
+	c := newFakeClock()
+	p := New(WithClock(c), WithTimeout(time.Minute))
+	assert.Equal(t, time.Minute, p.windowRemaining())
+
+	p.openWindow()
+	assert.Equal(t, time.Minute, p.windowRemaining())
+
+	p.closeWindow()
+	assert.Equal(t, time.Minute, p.windowRemaining())
+}
@@ -0,0 +1,51 @@
+package profiler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+)
+
+// envPath is the route of the runtime settings
+const envPath = "/debug/env"
+
+// Env are the settings of the Go runtime served on /debug/env
+type Env struct {
+	GoVersion  string `json:"goVersion"`
+	GOMAXPROCS int    `json:"GOMAXPROCS"`
+	NumCPU     int    `json:"numCPU"`
+	// GOGC is the current GC percentage, -1 if the GC is disabled
+	GOGC    int    `json:"GOGC"`
+	GODEBUG string `json:"GODEBUG"`
+	// GOMEMLIMIT is the current soft memory limit in bytes, it is not reported before go1.19
+	GOMEMLIMIT *int64 `json:"GOMEMLIMIT,omitempty"`
+}
+
+// WithEnvRoute enables the /debug/env route which serves the current settings of the Go runtime as JSON,
+// e.g. GOMAXPROCS, GOGC and the memory limit
+func WithEnvRoute(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.envRoute = enabled
+	}
+}
+
+// runtimeEnv returns the current settings of the Go runtime
+func runtimeEnv() Env {
+	gogc, limit := gcSettings()
+
+	return Env{
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		GOGC:       gogc,
+		GODEBUG:    os.Getenv("GODEBUG"),
+		GOMEMLIMIT: limit,
+	}
+}
+
+// serveEnv serves the settings of the Go runtime
+func serveEnv(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(runtimeEnv())
+}
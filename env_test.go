@@ -0,0 +1,35 @@
+package profiler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEnvRoute(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New().mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, envPath, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	gogc := debug.SetGCPercent(150)
+	defer debug.SetGCPercent(gogc)
+
+	rec = httptest.NewRecorder()
+	New(WithEnvRoute(true)).mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, envPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var env Env
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &env))
+	assert.Equal(t, runtime.Version(), env.GoVersion)
+	assert.Equal(t, runtime.GOMAXPROCS(0), env.GOMAXPROCS)
+	assert.Equal(t, 150, env.GOGC)
+
+	// reading the settings does not change them
+	assert.Equal(t, 150, runtimeEnv().GOGC)
+}
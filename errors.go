@@ -0,0 +1,58 @@
+package profiler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// nolint: gochecknoglobals
+var (
+	// ErrAlreadyRunning is returned if the signal handler of the Profiler is already running
+	ErrAlreadyRunning = errors.New("profiler already running")
+	// ErrBindFailed is matched by a BindError with errors.Is
+	ErrBindFailed = errors.New("bind failed")
+	// ErrInvalidAddress is returned if the listen address can not be parsed
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrUnknownSignal is returned if the name of the signal is not known
+	ErrUnknownSignal = errors.New("unknown signal")
+	// ErrUnsupportedSignal is returned if the signal can not be delivered on the platform
+	ErrUnsupportedSignal = errors.New("unsupported signal")
+	// ErrTerminationSignal is returned in strict mode if the activation signal terminates the process by default
+	ErrTerminationSignal = errors.New("termination signal")
+	// ErrStartTimeout is wrapped by the BindError if the listeners are not bound within the start timeout
+	ErrStartTimeout = errors.New("start timeout exceeded")
+	// ErrRouteConflict is returned if the pattern of an extra handler conflicts with another route
+	ErrRouteConflict = errors.New("route conflict")
+	// ErrInvalidTLS is returned by Validate if the pprof endpoint can not be served with TLS
+	ErrInvalidTLS = errors.New("invalid tls configuration")
+	// ErrUnknownProfile is returned if the name of a profile is not known
+	ErrUnknownProfile = errors.New("unknown profile")
+	// ErrCPUProfileInProgress is returned if a CPU profile is requested while another one is in progress
+	ErrCPUProfileInProgress = errors.New("cpu profile already in progress")
+	// ErrNotRunning is returned if the signal handler of the Profiler is not running
+	ErrNotRunning = errors.New("profiler not running")
+	// ErrWindowFailed is returned by TriggerAndWait if the pprof endpoint fails to start
+	ErrWindowFailed = errors.New("pprof endpoint failed to start")
+	// ErrInvalidInterval is returned if the interval of a periodic option is not positive
+	ErrInvalidInterval = errors.New("invalid interval")
+)
+
+// BindError is returned if the pprof endpoint fails to bind its listen address
+type BindError struct {
+	Address string
+	Err     error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("failed to bind %q: %v", e.Address, e.Err)
+}
+
+// Unwrap returns the underlying network error
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrBindFailed
+func (e *BindError) Is(target error) bool {
+	return target == ErrBindFailed
+}
@@ -0,0 +1,90 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithError(t *testing.T) {
+	p, err := NewWithError(WithAddress("localhost:8080"))
+	require.NoError(t, err)
+	assert.NotNil(t, p)
+
+	p, err = NewWithError(WithAddress("localhost"))
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+	assert.Nil(t, p)
+}
+
+func TestStartWithError(t *testing.T) {
+	p := New(WithSignal(testSignal), WithAddress(freeAddress(t)))
+
+	require.NoError(t, p.StartWithError())
+	assert.True(t, errors.Is(p.StartWithError(), ErrAlreadyRunning))
+	<-p.Started()
+	p.Stop()
+
+	require.NoError(t, p.StartWithError())
+	<-p.Started()
+	p.Stop()
+}
+
+func TestBindError(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	p := New(WithAddress(l.Addr().String()))
+	err = p.serve(context.Background(), &http.Server{Addr: p.address})
+
+	var bindErr *BindError
+
+	require.True(t, errors.As(err, &bindErr))
+	assert.Equal(t, p.address, bindErr.Address)
+	assert.True(t, errors.Is(err, ErrBindFailed))
+	assert.True(t, errors.Is(err, syscall.EADDRINUSE))
+}
+
+func TestWithStartConflictHandler(t *testing.T) {
+	r := &eventRecorder{}
+	conflicts := 0
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithEventHandler(r.handle),
+		WithStartConflictHandler(func() {
+			conflicts++
+		}),
+	)
+
+	p.Start()
+	p.Start()
+	<-p.Started()
+	p.Stop()
+
+	assert.Equal(t, 1, conflicts)
+	assert.Equal(t, 1, r.count(MsgStartIgnored))
+}
+
+func TestWithStrict(t *testing.T) {
+	assert.NotPanics(t, func() {
+		_ = New(WithStrict(true), WithAddress("localhost:8080"))
+	})
+
+	defer func() {
+		err, ok := recover().(error)
+		require.True(t, ok)
+		assert.True(t, errors.Is(err, ErrUnknownSignal))
+		assert.Contains(t, err.Error(), "invalid configuration")
+	}()
+
+	_ = New(WithStrict(true), WithSignalName("SIGFOO"))
+	t.Fatal("New must panic")
+}
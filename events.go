@@ -0,0 +1,266 @@
+package profiler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// EventType represents the severity of an event
+type EventType int
+
+// Event types
+const (
+	DebugEvent EventType = iota
+	InfoEvent
+	WarnEvent
+	ErrorEvent
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DebugEvent:
+		return "DEBUG"
+	case InfoEvent:
+		return "INFO"
+	case WarnEvent:
+		return "WARN"
+	case ErrorEvent:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// EventCode identifies an event emitted by the Profiler
+type EventCode int
+
+// Event codes
+const (
+	// CodeGeneric is used for events without a specific code
+	CodeGeneric EventCode = iota
+	CodeHandlerStarted
+	CodeHandlerStopped
+	CodeWindowOpening
+	CodeWindowOpened
+	CodeWindowClosing
+	CodeWindowClosed
+	CodeBindFailed
+	CodeEndpointFailed
+	CodeShutdownFailed
+	CodeStartCanceled
+	CodeProfileStarted
+	CodeProfileFinished
+	CodeAutoTriggered
+	CodeAutoTriggerSuppressed
+	CodeWindowPanic
+	CodeProfileCaptureFailed
+	CodeProfilePushFailed
+	CodeBindRetry
+	CodeSignalReceived
+	CodeHandlerPanic
+	CodeAccessLog
+	CodeClientDisconnected
+	CodeServerError
+	CodeStartIgnored
+	CodeWebhookFailed
+	CodeConfigured
+	CodeRequestLimit
+	CodeProfileCaptured
+	CodeWindowRejected
+	CodeNotCancelable
+	CodeInvalidOption
+	CodeSignalUnsupported
+	CodeTerminationSignal
+	CodeRouteConflict
+	CodeUnknownRoute
+	CodePatternSkipped
+	CodeGCParamsChanged
+	CodeExpvarPublished
+	CodeWriteFailed
+	CodeBundleFailed
+	CodeCompressFailed
+)
+
+// nolint: gochecknoglobals
+var eventCodeNames = map[EventCode]string{
+	CodeGeneric:        "Generic",
+	CodeHandlerStarted: "HandlerStarted",
+	CodeHandlerStopped: "HandlerStopped",
+	CodeWindowOpening:  "WindowOpening",
+	CodeWindowOpened:   "WindowOpened",
+	CodeWindowClosing:  "WindowClosing",
+	CodeWindowClosed:   "WindowClosed",
+	CodeBindFailed:     "BindFailed",
+	CodeEndpointFailed: "EndpointFailed",
+	CodeShutdownFailed: "ShutdownFailed",
+	CodeStartCanceled:  "StartCanceled",
+
+	CodeProfileStarted:  "ProfileStarted",
+	CodeProfileFinished: "ProfileFinished",
+	CodeAutoTriggered:   "AutoTriggered",
+
+	CodeAutoTriggerSuppressed: "AutoTriggerSuppressed",
+	CodeWindowPanic:           "WindowPanic",
+	CodeProfileCaptureFailed:  "ProfileCaptureFailed",
+	CodeProfilePushFailed:     "ProfilePushFailed",
+	CodeBindRetry:             "BindRetry",
+	CodeSignalReceived:        "SignalReceived",
+	CodeHandlerPanic:          "HandlerPanic",
+	CodeAccessLog:             "AccessLog",
+	CodeClientDisconnected:    "ClientDisconnected",
+	CodeServerError:           "ServerError",
+	CodeStartIgnored:          "StartIgnored",
+	CodeWebhookFailed:         "WebhookFailed",
+	CodeConfigured:            "Configured",
+	CodeRequestLimit:          "RequestLimit",
+	CodeProfileCaptured:       "ProfileCaptured",
+	CodeWindowRejected:        "WindowRejected",
+	CodeNotCancelable:         "NotCancelable",
+	CodeInvalidOption:         "InvalidOption",
+	CodeSignalUnsupported:     "SignalUnsupported",
+	CodeTerminationSignal:     "TerminationSignal",
+	CodeRouteConflict:         "RouteConflict",
+	CodeUnknownRoute:          "UnknownRoute",
+	CodePatternSkipped:        "PatternSkipped",
+	CodeGCParamsChanged:       "GCParamsChanged",
+	CodeExpvarPublished:       "ExpvarPublished",
+	CodeWriteFailed:           "WriteFailed",
+	CodeBundleFailed:          "BundleFailed",
+	CodeCompressFailed:        "CompressFailed",
+}
+
+func (c EventCode) String() string {
+	if name, ok := eventCodeNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("EventCode(%d)", int(c))
+}
+
+// Messages of the events emitted by the Profiler
+//
+// The messages are stable and can be used by an EventHandler to react to specific events.
+// Prefer the EventCode passed to an EventCodeHandler.
+const (
+	MsgHandlerStarted        = "start profiler handler"
+	MsgHandlerStopped        = "profiler handler stopped"
+	MsgEndpointStarting      = "start pprof endpoint"
+	MsgEndpointListening     = "pprof endpoint listening"
+	MsgEndpointFailed        = "failed to start pprof endpoint"
+	MsgEndpointStopped       = "pprof endpoint stopped"
+	MsgEndpointShutdown      = "shutdown pprof endpoint"
+	MsgEndpointShutdownFail  = "failed to shutdown pprof endpoint"
+	MsgStartCanceled         = "profiler handler not started, context is done"
+	MsgCPUProfileStarted     = "cpu profile started"
+	MsgCPUProfileFinished    = "cpu profile finished"
+	MsgAutoTriggered         = "auto trigger activated pprof endpoint"
+	MsgAutoTriggerSuppressed = "auto trigger suppressed by limit"
+	MsgWindowPanic           = "pprof endpoint panicked"
+	MsgProfileCaptureFailed  = "failed to capture profile"
+	MsgProfilePushFailed     = "failed to push profile"
+	MsgBindRetry             = "failed to bind pprof endpoint, retrying"
+	MsgSignalReceived        = "signal received"
+	MsgHandlerPanic          = "pprof handler panicked"
+	MsgAccessLog             = "pprof request"
+	MsgClientDisconnected    = "client disconnected"
+	MsgServerError           = "pprof server error"
+	MsgStartIgnored          = "profiler handler already running, start ignored"
+	MsgWebhookFailed         = "failed to notify activation webhook"
+	MsgConfigured            = "profiler configured"
+	MsgRequestLimit          = "request limit of pprof endpoint reached"
+	MsgProfileCaptured       = "profile captured"
+	MsgWindowRejected        = "activation rejected, too many open pprof endpoints"
+	MsgNotCancelable         = "context can not be canceled, stop the profiler handler with Stop"
+	MsgInvalidOption         = "invalid option ignored"
+	MsgSignalUnsupported     = "signal not supported"
+	MsgTerminationSignal     = "termination signal used as activation signal, the process does not shutdown on it"
+	MsgRouteConflict         = "pprof route conflict"
+	MsgUnknownRoute          = "unknown pprof route"
+	MsgPatternSkipped        = "pattern already registered, skipped"
+	MsgGCParamsChanged       = "gc parameters changed"
+	MsgExpvarPublished       = "expvar variable already published"
+	MsgWriteFailed           = "failed to write pprof response"
+	MsgBundleFailed          = "failed to write profile bundle"
+	MsgCompressFailed        = "failed to compress pprof response"
+)
+
+// EventHandler handles the events emitted by the Profiler
+// The args are alternating keys and values.
+type EventHandler func(t EventType, msg string, args ...interface{})
+
+// EventCodeHandler handles the events emitted by the Profiler like EventHandler,
+// the code identifies the event
+type EventCodeHandler func(t EventType, code EventCode, msg string, args ...interface{})
+
+// WithEventHandler sets the handler for the events of the Profiler
+// The default handler writes all events except DebugEvent to the standard logger.
+func WithEventHandler(h EventHandler) Opt {
+	return func(p *Profiler) {
+		p.eventHandler = func(t EventType, _ EventCode, msg string, args ...interface{}) {
+			h(t, msg, args...)
+		}
+	}
+}
+
+// WithEventCodeHandler sets the handler for the events of the Profiler, see WithEventHandler
+func WithEventCodeHandler(h EventCodeHandler) Opt {
+	return func(p *Profiler) {
+		p.eventHandler = h
+	}
+}
+
+// WithLogAttrs sets attributes (alternating keys and values) which are prepended to the args
+// of every event, e.g. "service", "api", "version", "1.2.3"
+func WithLogAttrs(args ...interface{}) Opt {
+	return func(p *Profiler) {
+		p.logAttrs = append(p.logAttrs, args...)
+	}
+}
+
+// evt emits an event
+func (p *Profiler) evt(t EventType, code EventCode, msg string, args ...interface{}) {
+	if len(p.logAttrs) > 0 {
+		args = append(append([]interface{}{}, p.logAttrs...), args...)
+	}
+
+	p.eventHandler(t, code, msg, args...)
+}
+
+// logEventHandler writes the events to the standard logger
+func logEventHandler(t EventType, _ EventCode, msg string, args ...interface{}) {
+	if t == DebugEvent {
+		return
+	}
+
+	var b strings.Builder
+
+	b.WriteString(msg)
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", args[i])
+		}
+	}
+
+	log.Println(b.String())
+}
+
+// eventWriter emits the lines written by the logger of the http server as events
+type eventWriter struct {
+	p *Profiler
+}
+
+func (w eventWriter) Write(b []byte) (int, error) {
+	line := strings.TrimSuffix(string(b), "\n")
+
+	if strings.Contains(line, "broken pipe") || strings.Contains(line, "connection reset by peer") {
+		w.p.evt(DebugEvent, CodeClientDisconnected, MsgClientDisconnected, "error", line)
+	} else {
+		w.p.evt(ErrorEvent, CodeServerError, MsgServerError, "error", line)
+	}
+
+	return len(b), nil
+}
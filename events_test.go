@@ -0,0 +1,137 @@
+package profiler
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	sync.Mutex
+	msgs []string
+}
+
+func (r *eventRecorder) handle(t EventType, msg string, args ...interface{}) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.msgs = append(r.msgs, msg)
+}
+
+func (r *eventRecorder) count(msg string) int {
+	r.Lock()
+	defer r.Unlock()
+
+	n := 0
+
+	for _, m := range r.msgs {
+		if m == msg {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestWithEventHandler(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle))
+	p.evt(InfoEvent, CodeHandlerStarted, MsgHandlerStarted)
+	assert.Equal(t, 1, r.count(MsgHandlerStarted))
+}
+
+func TestMultipleStartStop(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithEventHandler(r.handle),
+	)
+
+	for i := 0; i < 3; i++ {
+		p.Start()
+		<-p.Started()
+		p.Stop()
+	}
+
+	assert.Equal(t, 3, r.count(MsgHandlerStarted))
+	assert.Equal(t, 3, r.count(MsgHandlerStopped))
+}
+
+func TestEventTypeString(t *testing.T) {
+	assert.Equal(t, "INFO", InfoEvent.String())
+	assert.Equal(t, "EventType(42)", EventType(42).String())
+}
+
+func TestWithEventCodeHandler(t *testing.T) {
+	codes := make(chan EventCode, 10)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, _ ...interface{}) {
+			codes <- code
+		}),
+	)
+
+	p.Start()
+	<-p.Started()
+	p.Stop()
+	close(codes)
+
+	got := []EventCode{}
+	for c := range codes {
+		got = append(got, c)
+	}
+
+	// Start uses a context which can not be canceled
+	assert.Equal(t, []EventCode{CodeHandlerStarted, CodeNotCancelable, CodeHandlerStopped}, got)
+}
+
+func TestEventCodeString(t *testing.T) {
+	assert.Equal(t, "WindowOpened", CodeWindowOpened.String())
+	assert.Equal(t, "EventCode(42)", EventCode(42).String())
+}
+
+func TestWithLogAttrs(t *testing.T) {
+	var args []interface{}
+
+	p := New(
+		WithLogAttrs("service", "api", "version", "1.2.3"),
+		WithEventHandler(func(_ EventType, _ string, a ...interface{}) {
+			args = a
+		}),
+	)
+
+	p.evt(InfoEvent, CodeGeneric, "test", "key", "value")
+	assert.Equal(t, []interface{}{"service", "api", "version", "1.2.3", "key", "value"}, args)
+}
+
+func TestServerErrorLog(t *testing.T) {
+	type event struct {
+		t    EventType
+		code EventCode
+		args []interface{}
+	}
+
+	events := make(chan event, 2)
+	p := New(WithEventCodeHandler(func(t EventType, code EventCode, _ string, args ...interface{}) {
+		events <- event{t, code, args}
+	}))
+
+	srv := p.newServer(context.Background())
+	srv.ErrorLog.Printf("http: TLS handshake error from 127.0.0.1:1234: EOF")
+	srv.ErrorLog.Printf("write tcp 127.0.0.1:6666: write: broken pipe")
+
+	assert.Equal(t, event{ErrorEvent, CodeServerError, []interface{}{"error", "http: TLS handshake error from 127.0.0.1:1234: EOF"}}, <-events)
+	assert.Equal(t, event{DebugEvent, CodeClientDisconnected, []interface{}{"error", "write tcp 127.0.0.1:6666: write: broken pipe"}}, <-events)
+
+	// the logger of the template is kept
+	l := log.New(ioutil.Discard, "", 0)
+	p = New(WithHTTPServer(&http.Server{ErrorLog: l}))
+	assert.Equal(t, l, p.newServer(context.Background()).ErrorLog)
+}
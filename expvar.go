@@ -0,0 +1,126 @@
+package profiler
+
+import (
+	"encoding/json"
+	"expvar"
+	"strings"
+	"sync"
+)
+
+// expvarFunc is a named function published with expvar
+type expvarFunc struct {
+	name string
+	f    func() interface{}
+}
+
+// WithExpvarFunc publishes the function as expvar variable, which is served on /debug/vars
+// The variable is published by New, existing variables with the same name are not replaced.
+func WithExpvarFunc(name string, f func() interface{}) Opt {
+	return func(p *Profiler) {
+		p.expvars = append(p.expvars, expvarFunc{name: name, f: f})
+	}
+}
+
+// publishExpvars publishes the configured expvar functions
+func (p *Profiler) publishExpvars() {
+	for _, v := range p.expvars {
+		if expvar.Get(v.name) != nil {
+			p.evt(WarnEvent, CodeExpvarPublished, MsgExpvarPublished, "name", v.name)
+			continue
+		}
+
+		expvar.Publish(v.name, expvar.Func(v.f))
+	}
+}
+
+// configVar is the name of the expvar variable with the configuration of the Profiler
+const configVar = "profiler"
+
+// nolint: gochecknoglobals
+var (
+	configOnce sync.Once
+	configMu   sync.Mutex
+	configOf   *Profiler
+)
+
+// expvarConfig is the configuration of the Profiler published as expvar variable
+type expvarConfig struct {
+	Signal  string `json:"signal"`
+	Address string `json:"address"`
+	Network string `json:"network"`
+	Timeout string `json:"timeout"`
+	Auth    bool   `json:"auth"`
+	Running bool   `json:"running"`
+}
+
+// publishConfig publishes the configuration of the Profiler as expvar variable "profiler"
+// The variable is published only once, it reports the configuration of the most recently created Profiler.
+func (p *Profiler) publishConfig() {
+	configMu.Lock()
+	configOf = p
+	configMu.Unlock()
+
+	configOnce.Do(func() {
+		if expvar.Get(configVar) != nil {
+			p.evt(WarnEvent, CodeExpvarPublished, MsgExpvarPublished, "name", configVar)
+			return
+		}
+
+		expvar.Publish(configVar, expvar.Func(func() interface{} {
+			configMu.Lock()
+			p := configOf
+			configMu.Unlock()
+
+			return p.expvarConfig()
+		}))
+	})
+}
+
+// expvarConfig returns the configuration published as expvar variable
+func (p *Profiler) expvarConfig() expvarConfig {
+	p.Lock()
+	running := p.running
+	p.Unlock()
+
+	return expvarConfig{
+		Signal:  signalName(p.signal),
+		Address: p.Address(),
+		Network: p.listenNetwork(),
+		Timeout: p.timeout.String(),
+		Auth:    p.authFunc != nil,
+		Running: running,
+	}
+}
+
+// ExpvarSnapshot returns the current values of the expvar variables as served on /debug/vars, e.g. to embed
+// them in an error report. The values are decoded from JSON, numbers as json.Number to keep their precision.
+// The value of a variable which is not valid JSON is returned as string.
+func (p *Profiler) ExpvarSnapshot() map[string]interface{} {
+	vars := map[string]interface{}{}
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			vars[kv.Key] = p.cmdlineArgs()
+			return
+		}
+
+		vars[kv.Key] = expvarValue(kv)
+	})
+
+	return vars
+}
+
+// expvarValue returns the value of the variable decoded from JSON or as string if it is not valid JSON
+func expvarValue(kv expvar.KeyValue) interface{} {
+	s := kv.Value.String()
+
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.More() {
+		return s
+	}
+
+	return v
+}
@@ -0,0 +1,100 @@
+package profiler
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvars(t *testing.T) {
+	p := New()
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	vars := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "memstats")
+	assert.Contains(t, vars, "cmdline")
+}
+
+func TestWithExpvarFunc(t *testing.T) {
+	// expvar variables can not be removed, use a unique name for repeated test runs
+	name := fmt.Sprintf("test_counter_%d", time.Now().UnixNano())
+
+	r := &eventRecorder{}
+	p := New(
+		WithEventHandler(r.handle),
+		WithExpvarFunc(name, func() interface{} { return 42 }),
+	)
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	vars := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	assert.Equal(t, float64(42), vars[name])
+
+	// a second profiler must not panic on the already published variable
+	_ = New(
+		WithEventHandler(r.handle),
+		WithExpvarFunc(name, func() interface{} { return 0 }),
+	)
+	assert.Equal(t, 1, r.count(MsgExpvarPublished))
+}
+
+func TestConfigExpvar(t *testing.T) {
+	_ = New(WithAddress("localhost:6061"))
+	// the variable reports the most recently created profiler
+	p := New(WithSignal(testSignal), WithAddress("localhost:6062"), WithTimeout(time.Minute))
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	vars := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+
+	var c expvarConfig
+	require.NoError(t, json.Unmarshal(vars[configVar], &c))
+	assert.Equal(t, expvarConfig{
+		Signal:  signalName(testSignal),
+		Address: "localhost:6062",
+		Network: "tcp",
+		Timeout: "1m0s",
+	}, c)
+}
+
+// rawVar is an expvar variable whose value is not valid JSON
+type rawVar string
+
+func (v rawVar) String() string {
+	return string(v)
+}
+
+func TestExpvarValue(t *testing.T) {
+	assert.Equal(t, "not json", expvarValue(expvar.KeyValue{Key: "raw", Value: rawVar("not json")}))
+	assert.Equal(t, "1 2", expvarValue(expvar.KeyValue{Key: "raw", Value: rawVar("1 2")}))
+	assert.Equal(t, json.Number("12345678901234567890"),
+		expvarValue(expvar.KeyValue{Key: "int", Value: rawVar("12345678901234567890")}))
+}
+
+func TestExpvarSnapshot(t *testing.T) {
+	p := New(WithCmdline([]string{"app", "--token=***"}))
+	vars := p.ExpvarSnapshot()
+
+	assert.IsType(t, map[string]interface{}{}, vars["memstats"])
+	assert.Equal(t, []string{"app", "--token=***"}, vars["cmdline"])
+
+	_, err := json.Marshal(vars)
+	require.NoError(t, err)
+}
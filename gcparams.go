@@ -0,0 +1,91 @@
+package profiler
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+// gcParamsPath is the route to change the GC parameters
+const gcParamsPath = "/debug/gcparams"
+
+// GCParams are the previous GC parameters returned by /debug/gcparams
+type GCParams struct {
+	// GOGC is the previous GC percentage
+	GOGC *int `json:"gogc,omitempty"`
+	// MemLimit is the previous soft memory limit in bytes
+	MemLimit *int64 `json:"memlimit,omitempty"`
+}
+
+// WithGCParamsRoute enables the /debug/gcparams route which sets the GC percentage (form value gogc, see
+// debug.SetGCPercent) and the soft memory limit in bytes (form value memlimit, see debug.SetMemoryLimit,
+// go1.19 or newer) with a POST request. The previous values are returned as JSON, posting them restores
+// the settings. The settings are not restored when the pprof endpoint is shutdown.
+//
+// The route changes the behavior of the whole process, it is forbidden without WithAuthFunc.
+func WithGCParamsRoute(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.gcParamsRoute = enabled
+	}
+}
+
+// serveGCParams sets the GC parameters of the request and returns the previous values
+func (p *Profiler) serveGCParams(w http.ResponseWriter, r *http.Request) {
+	if p.authFunc == nil {
+		serveError(w, http.StatusForbidden, "gcparams requires authentication")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		serveError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+		return
+	}
+
+	var (
+		gogc     int
+		memLimit int64
+		err      error
+	)
+
+	// parse all values before any setting is changed
+	v := r.FormValue("gogc")
+	if v != "" {
+		if gogc, err = strconv.Atoi(v); err != nil {
+			serveError(w, http.StatusBadRequest, "invalid gogc")
+			return
+		}
+	}
+
+	l := r.FormValue("memlimit")
+	if l != "" {
+		if memLimit, err = strconv.ParseInt(l, 10, 64); err != nil || memLimit < 0 {
+			serveError(w, http.StatusBadRequest, "invalid memlimit")
+			return
+		}
+	}
+
+	var prev GCParams
+
+	if l != "" {
+		prevLimit, ok := setMemoryLimit(memLimit)
+		if !ok {
+			serveError(w, http.StatusNotImplemented, "memlimit requires go1.19")
+			return
+		}
+
+		prev.MemLimit = &prevLimit
+	}
+
+	if v != "" {
+		prevGOGC := debug.SetGCPercent(gogc)
+		prev.GOGC = &prevGOGC
+	}
+
+	p.evt(WarnEvent, CodeGCParamsChanged, MsgGCParamsChanged, "gogc", v, "memlimit", l, "remote", ClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(prev)
+}
@@ -0,0 +1,80 @@
+package profiler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithGCParamsRoute(t *testing.T) {
+	post := func(h http.Handler, values url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, gcParamsPath, strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		return rec
+	}
+
+	// forbidden without authentication
+	h := New(WithGCParamsRoute(true)).mux()
+	assert.Equal(t, http.StatusForbidden, post(h, url.Values{"gogc": {"50"}}).Code)
+
+	r := &eventRecorder{}
+	h = New(
+		WithGCParamsRoute(true),
+		WithAuthFunc(func(*http.Request) bool { return true }),
+		WithEventHandler(r.handle),
+	).mux()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, gcParamsPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	assert.Equal(t, http.StatusBadRequest, post(h, url.Values{"gogc": {"x"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, post(h, url.Values{"gogc": {"50"}, "memlimit": {"-1"}}).Code)
+
+	gogc := debug.SetGCPercent(120)
+	defer debug.SetGCPercent(gogc)
+
+	rec = post(h, url.Values{"gogc": {"50"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var prev GCParams
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prev))
+	require.NotNil(t, prev.GOGC)
+	assert.Equal(t, 120, *prev.GOGC)
+	assert.Nil(t, prev.MemLimit)
+	assert.Equal(t, 50, runtimeEnv().GOGC)
+	// the change of the process-wide settings is audited
+	assert.Equal(t, 1, r.count(MsgGCParamsChanged))
+
+	// posting the previous values restores the settings
+	rec = post(h, url.Values{"gogc": {"120"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 120, runtimeEnv().GOGC)
+
+	if _, limit := gcSettings(); limit != nil {
+		rec = post(h, url.Values{"memlimit": {"1073741824"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		prev = GCParams{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &prev))
+		require.NotNil(t, prev.MemLimit)
+		assert.Equal(t, *limit, *prev.MemLimit)
+		assert.Equal(t, int64(1<<30), *runtimeEnv().GOMEMLIMIT)
+
+		rec = post(h, url.Values{"memlimit": {strconv.FormatInt(*prev.MemLimit, 10)}})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, *limit, *runtimeEnv().GOMEMLIMIT)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !go1.21
+// +build !go1.21
+
+package profiler
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// gcPercentMu serializes the reads of the GC percentage
+// nolint: gochecknoglobals
+var gcPercentMu sync.Mutex
+
+// readGCPercent returns the GC percentage, it can only be read by setting it: the GC is disabled
+// for the moment between the two calls
+func readGCPercent() int {
+	gcPercentMu.Lock()
+	defer gcPercentMu.Unlock()
+
+	gogc := debug.SetGCPercent(-1)
+	debug.SetGCPercent(gogc)
+
+	return gogc
+}
@@ -0,0 +1,14 @@
+//go:build !go1.19
+// +build !go1.19
+
+package profiler
+
+// gcSettings returns the GC percentage, the memory limit is not available before go1.19
+func gcSettings() (gogc int, limit *int64) {
+	return readGCPercent(), nil
+}
+
+// setMemoryLimit is not supported before go1.19
+func setMemoryLimit(int64) (prev int64, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,17 @@
+//go:build go1.19 && !go1.21
+// +build go1.19,!go1.21
+
+package profiler
+
+import "runtime/debug"
+
+// gcSettings returns the GC percentage and the memory limit, a negative limit reads it without changing it
+func gcSettings() (gogc int, limit *int64) {
+	l := debug.SetMemoryLimit(-1)
+	return readGCPercent(), &l
+}
+
+// setMemoryLimit sets the memory limit and returns the previous limit
+func setMemoryLimit(limit int64) (prev int64, ok bool) {
+	return debug.SetMemoryLimit(limit), true
+}
@@ -0,0 +1,28 @@
+//go:build go1.21
+// +build go1.21
+
+package profiler
+
+import (
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+)
+
+// gcSettings returns the GC percentage and the memory limit, read from runtime/metrics without changing them
+func gcSettings() (gogc int, limit *int64) {
+	samples := []rtmetrics.Sample{
+		{Name: "/gc/gogc:percent"},
+		{Name: "/gc/gomemlimit:bytes"},
+	}
+
+	rtmetrics.Read(samples)
+
+	l := int64(samples[1].Value.Uint64())
+
+	return int(samples[0].Value.Uint64()), &l
+}
+
+// setMemoryLimit sets the memory limit and returns the previous limit
+func setMemoryLimit(limit int64) (prev int64, ok bool) {
+	return debug.SetMemoryLimit(limit), true
+}
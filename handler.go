@@ -0,0 +1,46 @@
+package profiler
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Handler returns the handler of the pprof endpoint with all routes and middlewares, e.g. to serve it
+// on the server of the application with http.Handle("/debug/", p.Handler()) instead of the signal activated endpoint.
+// More specific patterns registered on the mux take precedence, e.g. /debug/pprof/ registered by net/http/pprof.
+func (p *Profiler) Handler() http.Handler {
+	return p.mux()
+}
+
+// Register registers the routes of the pprof endpoint (with the middlewares) on the mux, e.g. http.DefaultServeMux.
+// Patterns already registered on the mux (e.g. the handlers of net/http/pprof registered by the application)
+// are skipped with a WarnEvent instead of the panic of http.ServeMux.Handle, they are returned. The pattern "/"
+// (below the path prefix) is never registered, it belongs to the application.
+func (p *Profiler) Register(mux *http.ServeMux) []string {
+	h := p.mux()
+
+	var skipped []string
+
+	for _, pattern := range p.RegisteredRoutes() {
+		if pattern == p.pathPrefix+"/" {
+			continue
+		}
+
+		if registered(mux, pattern) {
+			p.evt(WarnEvent, CodePatternSkipped, MsgPatternSkipped, "pattern", pattern)
+			skipped = append(skipped, pattern)
+
+			continue
+		}
+
+		mux.Handle(pattern, h)
+	}
+
+	return skipped
+}
+
+// registered reports whether the pattern is registered on the mux
+func registered(mux *http.ServeMux, pattern string) bool {
+	_, p := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: pattern}})
+	return p == pattern
+}
@@ -0,0 +1,51 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/", New().Handler())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRegister(t *testing.T) {
+	// the application registered the handlers of net/http/pprof
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle))
+
+	var skipped []string
+
+	assert.NotPanics(t, func() {
+		skipped = p.Register(mux)
+	})
+	assert.Equal(t, []string{"/debug/pprof/", "/debug/pprof/cmdline"}, skipped)
+	assert.Equal(t, 2, r.count(MsgPatternSkipped))
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/vars"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+	}
+
+	// the root of the application is not registered
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// a second registration skips all patterns
+	assert.Len(t, p.Register(mux), len(p.RegisteredRoutes())-1)
+}
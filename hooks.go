@@ -0,0 +1,124 @@
+package profiler
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// HookerContext represents the interface for Profiler hooks which receive a context,
+// the configuration of the Profiler is available with ConfigFromContext and the reason of the activation
+// with ReasonFromContext
+//
+// The context is canceled when the context passed to StartContext is done or Stop is called, a slow
+// PreStart hook should abort then. The pprof endpoint is not started if the context is canceled by then.
+type HookerContext interface {
+	// PreStart will be executed after the signal was received but before the pprof endpoint starts
+	PreStart(ctx context.Context)
+	// PostShutdown will be executed after the pprof endpoint is shutdown
+	PostShutdown(ctx context.Context)
+}
+
+// BindHooker can optionally be implemented by a Hooker or HookerContext to run hooks around the bind
+// of the listeners. PreStart is executed first, PreBind directly before the listeners are bound,
+// PostBind with every bound address after the pprof endpoint is accepting connections.
+type BindHooker interface {
+	PreBind()
+	PostBind(addr string)
+}
+
+// ShutdownHooker can optionally be implemented by a Hooker or HookerContext to run a hook
+// when the pprof endpoint is about to be shutdown (timeout, drain signal or stop), while it is still serving.
+// The order is PreShutdown, shutdown of the endpoint, PostShutdown.
+type ShutdownHooker interface {
+	PreShutdown()
+}
+
+// Config is the configuration of the Profiler
+type Config struct {
+	Address string
+	Timeout time.Duration
+	Signal  os.Signal
+}
+
+type configKey struct{}
+
+// ConfigFromContext returns the configuration of the Profiler passed to a HookerContext
+func ConfigFromContext(ctx context.Context) (Config, bool) {
+	c, ok := ctx.Value(configKey{}).(Config)
+	return c, ok
+}
+
+// WithContextHooks registers the Profiler hooks receiving a context
+// The hooks are executed in the order of registration, together with the hooks of WithHooks.
+func WithContextHooks(hooks ...HookerContext) Opt {
+	return func(p *Profiler) {
+		p.hooks = append(p.hooks, hooks...)
+	}
+}
+
+// Config returns the configuration of the Profiler
+func (p *Profiler) Config() Config {
+	return Config{
+		Address: p.Address(),
+		Timeout: p.timeout,
+		Signal:  p.signal,
+	}
+}
+
+// hookContext returns the context passed to the hooks
+func (p *Profiler) hookContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, configKey{}, p.Config())
+	return context.WithValue(ctx, reasonKey{}, p.reason())
+}
+
+// hooker adapts a Hooker to a HookerContext
+type hooker struct {
+	Hooker
+}
+
+func (h hooker) PreStart(context.Context) {
+	h.Hooker.PreStart()
+}
+
+func (h hooker) PostShutdown(context.Context) {
+	h.Hooker.PostShutdown()
+}
+
+// unwrapHook returns the hook registered by WithHooks or WithContextHooks
+func unwrapHook(h HookerContext) interface{} {
+	if w, ok := h.(hooker); ok {
+		return w.Hooker
+	}
+
+	return h
+}
+
+// runPreShutdownHooks executes the PreShutdown hooks
+func (p *Profiler) runPreShutdownHooks() {
+	for _, h := range p.hooks {
+		if s, ok := unwrapHook(h).(ShutdownHooker); ok {
+			s.PreShutdown()
+		}
+	}
+}
+
+// runPreBindHooks executes the PreBind hooks
+func (p *Profiler) runPreBindHooks() {
+	for _, h := range p.hooks {
+		if b, ok := unwrapHook(h).(BindHooker); ok {
+			b.PreBind()
+		}
+	}
+}
+
+// runPostBindHooks executes the PostBind hooks for every address
+func (p *Profiler) runPostBindHooks(addrs []string) {
+	for _, h := range p.hooks {
+		if b, ok := unwrapHook(h).(BindHooker); ok {
+			for _, addr := range addrs {
+				b.PostBind(addr)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type configHook chan Config
+
+func (h configHook) PreStart(ctx context.Context) {
+	c, _ := ConfigFromContext(ctx)
+	h <- c
+}
+
+func (h configHook) PostShutdown(ctx context.Context) {
+	c, _ := ConfigFromContext(ctx)
+	h <- c
+}
+
+func TestWithContextHooks(t *testing.T) {
+	for name, activate := range activations {
+		activate := activate
+
+		t.Run(name, func(t *testing.T) {
+			c := newFakeClock()
+			address := freeAddress(t)
+			hook := make(configHook, 2)
+			p := New(
+				WithSignal(testSignal),
+				WithAddress(address),
+				WithTimeout(time.Minute),
+				WithClock(c),
+				WithContextHooks(hook),
+			)
+
+			expected := Config{Address: address, Timeout: time.Minute, Signal: testSignal}
+			assert.Equal(t, expected, p.Config())
+
+			timer := openWindowWith(t, p, c, activate)
+			assert.Equal(t, expected, <-hook)
+
+			timer.fire()
+			assert.Equal(t, expected, <-hook)
+
+			p.Stop()
+		})
+	}
+}
+
+func TestConfigFromContext(t *testing.T) {
+	_, ok := ConfigFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+type bindHook struct {
+	calls chan string
+}
+
+func (h bindHook) PreStart()     { h.calls <- "PreStart" }
+func (h bindHook) PostShutdown() {}
+func (h bindHook) PreBind()      { h.calls <- "PreBind" }
+
+func (h bindHook) PostBind(addr string) {
+	h.calls <- "PostBind " + addr
+}
+
+func TestBindHooker(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	hook := bindHook{calls: make(chan string, 3)}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithHooks(hook),
+	)
+
+	timer := openWindow(t, p, c)
+	assert.Equal(t, "PreStart", <-hook.calls)
+	assert.Equal(t, "PreBind", <-hook.calls)
+	assert.Equal(t, "PostBind "+address, <-hook.calls)
+
+	timer.fire()
+	p.Stop()
+}
+
+type preShutdownHook struct {
+	address string
+	calls   chan string
+}
+
+func (h preShutdownHook) PreStart() {}
+
+func (h preShutdownHook) PreShutdown() {
+	// the endpoint is still serving
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", h.address))
+	if err == nil {
+		_ = resp.Body.Close()
+		h.calls <- "PreShutdown"
+
+		return
+	}
+
+	h.calls <- err.Error()
+}
+
+func (h preShutdownHook) PostShutdown() {
+	h.calls <- "PostShutdown"
+}
+
+func TestShutdownHooker(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	hook := preShutdownHook{address: address, calls: make(chan string, 2)}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithHooks(hook),
+	)
+
+	timer := openWindow(t, p, c)
+	timer.fire()
+
+	assert.Equal(t, "PreShutdown", <-hook.calls)
+	assert.Equal(t, "PostShutdown", <-hook.calls)
+
+	p.Stop()
+}
+
+type slowHook struct {
+	started chan struct{}
+	aborted chan error
+}
+
+func (h slowHook) PreStart(ctx context.Context) {
+	close(h.started)
+
+	select {
+	case <-ctx.Done():
+		h.aborted <- ctx.Err()
+	case <-time.After(time.Minute):
+		h.aborted <- nil
+	}
+}
+
+func (h slowHook) PostShutdown(context.Context) {}
+
+func TestSlowPreStartHookCanceled(t *testing.T) {
+	r := &eventRecorder{}
+	hook := slowHook{started: make(chan struct{}), aborted: make(chan error, 1)}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithEventHandler(r.handle),
+		WithContextHooks(hook),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, p.StartContext(ctx))
+	<-p.Started()
+
+	assert.NoError(t, p.Trigger(context.Background()))
+	<-hook.started
+
+	start := time.Now()
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-hook.aborted)
+	p.Stop()
+
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 0, r.count(MsgEndpointListening))
+	assert.Equal(t, 1, r.count(MsgHandlerStopped))
+}
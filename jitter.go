@@ -0,0 +1,43 @@
+package profiler
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// nolint: gochecknoglobals
+var (
+	// jitterRand is the source of the timeout jitter, rand.Rand is not safe for concurrent use
+	jitterRand   = rand.New(rand.NewSource(time.Now().UnixNano())) // nolint: gosec
+	jitterRandMu sync.Mutex
+)
+
+// WithTimeoutJitter randomizes the timeout of every activation within ±frac of the timeout
+// (e.g. 0.1 for ±10%), so the windows of many replicas activated together do not close at the same moment.
+// frac is limited to [0, 1).
+func WithTimeoutJitter(frac float64) Opt {
+	return func(p *Profiler) {
+		switch {
+		case frac < 0:
+			frac = 0
+		case frac >= 1:
+			frac = 0.99
+		}
+
+		p.timeoutJitter = frac
+	}
+}
+
+// jitter returns the duration randomized within ±frac
+func jitter(d time.Duration, frac float64) time.Duration {
+	if frac <= 0 {
+		return d
+	}
+
+	jitterRandMu.Lock()
+	r := jitterRand.Float64()
+	jitterRandMu.Unlock()
+
+	return time.Duration(float64(d) * (1 + frac*(2*r-1)))
+}
@@ -0,0 +1,29 @@
+package profiler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTimeoutJitter(t *testing.T) {
+	assert.Equal(t, 0.0, New(WithTimeoutJitter(-1)).timeoutJitter)
+	assert.Equal(t, 0.99, New(WithTimeoutJitter(2)).timeoutJitter)
+
+	p := New(WithTimeout(time.Minute), WithTimeoutJitter(0.1))
+	seen := map[time.Duration]bool{}
+
+	for i := 0; i < 100; i++ {
+		d := p.activationTimeout()
+		assert.GreaterOrEqual(t, int64(d), int64(54*time.Second))
+		assert.LessOrEqual(t, int64(d), int64(66*time.Second))
+
+		seen[d] = true
+	}
+
+	assert.Greater(t, len(seen), 1, "the timeout is randomized")
+
+	// without jitter the timeout is unchanged
+	assert.Equal(t, time.Minute, New(WithTimeout(time.Minute)).activationTimeout())
+}
@@ -0,0 +1,138 @@
+package profiler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cheapRoutes are the routes below /debug/pprof/ which do not collect a profile
+// nolint: gochecknoglobals
+var cheapRoutes = map[string]bool{
+	"/debug/pprof/":        true,
+	"/debug/pprof/cmdline": true,
+	"/debug/pprof/symbol":  true,
+}
+
+// WithMaxRequestsPerWindow limits the number of requests collecting a profile (e.g. /debug/pprof/profile,
+// /debug/pprof/heap, /debug/bundle) per activation. After the n-th request the pprof endpoint is shutdown,
+// further requests are answered with 503 Service Unavailable. Other routes like /debug/vars are not counted.
+func WithMaxRequestsPerWindow(n int) Opt {
+	return func(p *Profiler) {
+		p.maxRequests = n
+	}
+}
+
+// profileRoute reports whether the request collects a profile
+func profileRoute(path string) bool {
+	if path == bundlePath {
+		return true
+	}
+
+	return strings.HasPrefix(path, "/debug/pprof/") && !cheapRoutes[path]
+}
+
+// limitRequests shuts the pprof endpoint down after the maximum number of profile requests,
+// the counter is reset for every activation because the handler is built per activation
+func (p *Profiler) limitRequests(next http.Handler) http.Handler {
+	var count int64
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !profileRoute(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		n := atomic.AddInt64(&count, 1)
+		if n > int64(p.maxRequests) {
+			http.Error(w, "request limit of pprof endpoint reached", http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+
+		if n == int64(p.maxRequests) {
+			p.evt(InfoEvent, CodeRequestLimit, MsgRequestLimit, "max", p.maxRequests)
+
+			select {
+			case p.requestLimit <- struct{}{}:
+			default:
+			}
+		}
+	})
+}
+
+// WithProfileQuota limits the number of successful requests collecting a profile to n per reset period,
+// further requests are answered with 429 Too Many Requests and a Retry-After header until the quota resets.
+// Unlike WithMaxRequestsPerWindow the pprof endpoint stays open, e.g. to share it during a debug session.
+// The quota starts with every activation.
+func WithProfileQuota(n int, reset time.Duration) Opt {
+	return func(p *Profiler) {
+		p.quota = n
+		p.quotaReset = reset
+	}
+}
+
+// profileQuota answers the requests collecting a profile with 429 Too Many Requests if the quota is used up,
+// the quota is reset for every activation because the handler is built per activation
+func (p *Profiler) profileQuota(next http.Handler) http.Handler {
+	var (
+		mu    sync.Mutex
+		start = p.clock.Now()
+		used  int
+	)
+
+	// reserve takes a slot of the quota or returns the time until the quota is reset
+	reserve := func() (bool, time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := p.clock.Now()
+		if elapsed := now.Sub(start); elapsed >= p.quotaReset {
+			start = now.Add(-(elapsed % p.quotaReset))
+			used = 0
+		}
+
+		if used >= p.quota {
+			return false, p.quotaReset - now.Sub(start)
+		}
+
+		used++
+
+		return true, 0
+	}
+
+	release := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		used--
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !profileRoute(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, retry := reserve()
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			http.Error(w, "profile quota of pprof endpoint exceeded", http.StatusTooManyRequests)
+
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		// only successful profiles use the quota
+		if sw.status >= http.StatusBadRequest {
+			release()
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileRoute(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"/debug/pprof/heap":      true,
+		"/debug/pprof/goroutine": true,
+		"/debug/pprof/profile":   true,
+		bundlePath:               true,
+		"/debug/pprof/":          false,
+		"/debug/pprof/cmdline":   false,
+		"/debug/vars":            false,
+	} {
+		assert.Equal(t, expected, profileRoute(path), path)
+	}
+}
+
+func TestWithMaxRequestsPerWindow(t *testing.T) {
+	stats := make(chan WindowStats, 1)
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithMaxRequestsPerWindow(2),
+		WithOnWindowClose(func(s WindowStats) {
+			stats <- s
+		}),
+	)
+
+	get := func(path string) int {
+		resp, err := http.Get("http://" + p.Address() + path)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	openWindow(t, p, c)
+
+	// cheap routes are not counted
+	assert.Equal(t, http.StatusOK, get("/debug/vars"))
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/heap"))
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/goroutine"))
+
+	assert.Equal(t, CloseLimit, (<-stats).Reason)
+
+	// the limit applies per activation
+	openWindow(t, p, c)
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/heap"))
+	p.Stop()
+	assert.Equal(t, CloseStopped, (<-stats).Reason)
+}
+
+// manualClock is a clock with a manually advanced time
+type manualClock struct {
+	*fakeClock
+	sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.now
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestWithProfileQuota(t *testing.T) {
+	c := &manualClock{fakeClock: newFakeClock(), now: time.Now()}
+	h := New(WithClock(c), WithProfileQuota(1, time.Minute)).mux()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	// a failed profile does not use the quota
+	assert.Equal(t, http.StatusNotFound, get("/debug/pprof/unknown").Code)
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/heap").Code)
+
+	rec := get("/debug/pprof/heap")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// other routes are not limited
+	assert.Equal(t, http.StatusOK, get("/debug/vars").Code)
+
+	c.advance(45 * time.Second)
+	assert.Equal(t, "15", get("/debug/pprof/heap").Header().Get("Retry-After"))
+
+	c.advance(15 * time.Second)
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/heap").Code)
+}
@@ -0,0 +1,483 @@
+package profiler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultReadyTimeout is the default timeout of the readiness probe
+const defaultReadyTimeout = 2 * time.Second
+
+// probeHeader marks the request of the readiness probe with the probe ID of the Profiler
+const probeHeader = "X-Profiler-Probe"
+
+// WithAddresses sets multiple listen addresses for the pprof endpoint, e.g. to listen explicitly
+// on IPv4 and IPv6 ("127.0.0.1:6666", "[::1]:6666"). The same handler is served on all addresses.
+// Address returns the first address.
+func WithAddresses(addrs ...string) Opt {
+	return func(p *Profiler) {
+		if len(addrs) == 0 {
+			return
+		}
+
+		p.address = addrs[0]
+		p.addresses = append([]string(nil), addrs...)
+	}
+}
+
+// WithOnListen registers a callback which is called with the bound addresses
+// when the pprof endpoint is listening
+func WithOnListen(f func(addrs []string)) Opt {
+	return func(p *Profiler) {
+		p.onListen = f
+	}
+}
+
+// WithReadinessProbe enables a request to the pprof endpoint after binding the listener.
+// The endpoint is only reported as listening (event, WithOnListen and WindowOpen) after the probe succeeded.
+func WithReadinessProbe(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.readinessProbe = enabled
+	}
+}
+
+// WithReadyTimeout sets the time the readiness probe waits for the response of the pprof endpoint (default: 2s),
+// independent of the timeout of the window and of the client set with WithHTTPClient. If the probe times out,
+// the endpoint is closed and reported as failed.
+func WithReadyTimeout(d time.Duration) Opt {
+	return func(p *Profiler) {
+		p.readyTimeout = d
+	}
+}
+
+// readinessTimeout returns the timeout of the readiness probe
+func (p *Profiler) readinessTimeout() time.Duration {
+	if p.readyTimeout > 0 {
+		return p.readyTimeout
+	}
+
+	return defaultReadyTimeout
+}
+
+// WithListenConfig sets the configuration used to bind the listeners of the pprof endpoint,
+// e.g. to set socket options like SO_REUSEADDR with a Control function.
+// The listeners are bound with the context of the signal handler.
+//
+// The Control function runs before the socket listens, the backlog can not be set there, see WithListenBacklog.
+func WithListenConfig(lc net.ListenConfig) Opt {
+	return func(p *Profiler) {
+		p.listenConfig = lc
+	}
+}
+
+// WithListenBacklog sets the length of the queue of pending connections of the listeners (default: the system's
+// SOMAXCONN), e.g. to accept a burst of connections when the pprof endpoint is opened fleet-wide.
+// The kernel may cap the backlog, on Linux to net.core.somaxconn.
+func WithListenBacklog(n int) Opt {
+	return func(p *Profiler) {
+		p.listenBacklog = n
+	}
+}
+
+// setBacklog sets the backlog of the listener by listening again on its socket
+func setBacklog(l net.Listener, n int) error {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("listener %T does not support a backlog", l)
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var listenErr error
+
+	if err := rc.Control(func(fd uintptr) {
+		listenErr = listenSocket(fd, n)
+	}); err != nil {
+		return err
+	}
+
+	return listenErr
+}
+
+// WithPortRange binds the pprof endpoint to the first free port between low and high (inclusive),
+// the port of the listen address is ignored. Address returns the chosen port once the endpoint is listening.
+func WithPortRange(low, high int) Opt {
+	return func(p *Profiler) {
+		p.portLow = low
+		p.portHigh = high
+	}
+}
+
+// WithBindRetry retries a failed bind of the pprof endpoint up to attempts times, waiting backoff
+// between the attempts, e.g. if the address of a previous instance is still in use during a rolling restart.
+// Every retry emits an event.
+func WithBindRetry(attempts int, backoff time.Duration) Opt {
+	return func(p *Profiler) {
+		p.bindRetries = attempts
+		p.bindBackoff = backoff
+	}
+}
+
+// WithStartTimeout aborts the start of the pprof endpoint if the listeners are not bound within d,
+// an error event is emitted and the PostShutdown hooks are executed
+func WithStartTimeout(d time.Duration) Opt {
+	return func(p *Profiler) {
+		p.startTimeout = d
+	}
+}
+
+// WithListenNetwork sets the network of the listeners (default: "tcp"), e.g. "tcp4" to bind only IPv4
+// on a dual-stack host or "unix" to listen on a unix socket (the address is the path of the socket)
+func WithListenNetwork(network string) Opt {
+	return func(p *Profiler) {
+		p.network = network
+	}
+}
+
+// listenNetwork returns the network of the listeners
+func (p *Profiler) listenNetwork() string {
+	if p.network == "" {
+		return "tcp"
+	}
+
+	return p.network
+}
+
+// listenAddresses returns the configured listen addresses
+func (p *Profiler) listenAddresses() []string {
+	if len(p.addresses) > 0 {
+		return p.addresses
+	}
+
+	return []string{p.address}
+}
+
+// serve binds the listeners and serves the pprof endpoint until the server is shutdown
+func (p *Profiler) serve(ctx context.Context, srv *http.Server) error {
+	p.runPreBindHooks()
+
+	listeners, err := p.bindWithTimeout(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.portLow > 0 {
+		p.setBoundPort(listeners[0])
+	}
+
+	// the server modifies TLSConfig while serving, therefore check it only once
+	useTLS := srv.TLSConfig != nil
+	errC := make(chan error, len(listeners))
+
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if useTLS {
+				errC <- srv.ServeTLS(l, "", "")
+				return
+			}
+
+			errC <- srv.Serve(l)
+		}(l)
+	}
+
+	if p.readinessProbe {
+		if err := p.probe(ctx, listeners[0].Addr(), useTLS); err != nil {
+			_ = srv.Close()
+
+			for range listeners {
+				<-errC
+			}
+
+			return fmt.Errorf("readiness probe failed: %w", err)
+		}
+	}
+
+	// a panic in the PostBind hooks or the OnListen callback must not leave the endpoint serving,
+	// the listeners are closed before the panic reaches the recover of the window
+	serving := false
+
+	defer func() {
+		if !serving {
+			_ = srv.Close()
+
+			for range listeners {
+				<-errC
+			}
+		}
+	}()
+
+	addrs := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		addrs = append(addrs, l.Addr().String())
+	}
+
+	// container CPU limits often make GOMAXPROCS and the number of CPUs diverge
+	p.evt(InfoEvent, CodeWindowOpened, MsgEndpointListening, p.withReason(
+		"address", strings.Join(addrs, ","),
+		"gomaxprocs", runtime.GOMAXPROCS(0),
+		"numcpu", runtime.NumCPU(),
+	)...)
+	p.runPostBindHooks(addrs)
+	p.openWindow()
+
+	if p.webhookURL != "" {
+		go p.notifyWebhook(addrs)
+	}
+
+	if p.onListen != nil {
+		p.onListen(addrs)
+	}
+
+	serving = true
+
+	err = <-errC
+	if err != http.ErrServerClosed {
+		// stop serving on the remaining listeners
+		_ = srv.Close()
+	}
+
+	for range listeners[1:] {
+		<-errC
+	}
+
+	return err
+}
+
+// bindWithTimeout binds the listeners like bind, but gives up after the start timeout
+// Listeners bound after the timeout are closed.
+func (p *Profiler) bindWithTimeout(ctx context.Context) ([]net.Listener, error) {
+	if p.startTimeout <= 0 {
+		return p.bind(ctx)
+	}
+
+	type result struct {
+		listeners []net.Listener
+		err       error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resC := make(chan result, 1)
+
+	go func() {
+		l, err := p.bind(ctx)
+		resC <- result{l, err}
+	}()
+
+	timer := p.clock.NewTimer(p.startTimeout)
+
+	select {
+	case res := <-resC:
+		timer.Stop()
+		return res.listeners, res.err
+	case <-timer.C():
+		go func() {
+			closeListeners((<-resC).listeners)
+		}()
+
+		return nil, &BindError{Address: p.address, Err: ErrStartTimeout}
+	}
+}
+
+// bind binds the listeners of all listen addresses and retries a failed bind if configured
+func (p *Profiler) bind(ctx context.Context) ([]net.Listener, error) {
+	for attempt := 1; ; attempt++ {
+		listeners, err := p.bindOnce(ctx)
+		if err == nil || attempt > p.bindRetries {
+			return listeners, err
+		}
+
+		p.evt(WarnEvent, CodeBindRetry, MsgBindRetry, "attempt", attempt, "error", err)
+
+		timer := p.clock.NewTimer(p.bindBackoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C():
+		}
+	}
+}
+
+// bindOnce binds the listeners of all listen addresses
+func (p *Profiler) bindOnce(ctx context.Context) ([]net.Listener, error) {
+	if p.portLow > 0 {
+		return p.bindPortRange(ctx)
+	}
+
+	return p.listen(ctx, p.listenAddresses())
+}
+
+// listen binds a listener for every address
+func (p *Profiler) listen(ctx context.Context, addrs []string) ([]net.Listener, error) {
+	listeners := []net.Listener{}
+
+	for _, addr := range addrs {
+		l, err := p.listenConfig.Listen(ctx, p.listenNetwork(), addr)
+		if err == nil && p.listenBacklog > 0 {
+			if err = setBacklog(l, p.listenBacklog); err != nil {
+				_ = l.Close()
+			}
+		}
+
+		if err != nil {
+			closeListeners(listeners)
+			return nil, &BindError{Address: addr, Err: err}
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// bindPortRange binds the listen addresses to the first port of the range which is free on all addresses,
+// errors other than an address in use are returned immediately. The address is not changed, see setBoundPort.
+func (p *Profiler) bindPortRange(ctx context.Context) ([]net.Listener, error) {
+	addrs := p.listenAddresses()
+	hosts := make([]string, 0, len(addrs))
+
+	for _, addr := range addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, &BindError{Address: addr, Err: err}
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	for port := p.portLow; port <= p.portHigh; port++ {
+		candidates := make([]string, 0, len(hosts))
+		for _, host := range hosts {
+			candidates = append(candidates, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, &BindError{Address: candidates[0], Err: err}
+		}
+
+		listeners, err := p.listen(ctx, candidates)
+		if errors.Is(err, errAddrInUse) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return listeners, nil
+	}
+
+	return nil, &BindError{
+		Address: net.JoinHostPort(hosts[0], fmt.Sprintf("%d-%d", p.portLow, p.portHigh)),
+		Err:     errors.New("no free port in range"),
+	}
+}
+
+// setBoundPort sets the port of the listener bound in the port range in the address reported by Address,
+// only a served endpoint changes it (Validate binds the port range as well)
+func (p *Profiler) setBoundPort(l net.Listener) {
+	tcp, ok := l.Addr().(*net.TCPAddr)
+	if !ok {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	host, _, err := net.SplitHostPort(p.listenAddresses()[0])
+	if err != nil {
+		return
+	}
+
+	p.address = net.JoinHostPort(host, strconv.Itoa(tcp.Port))
+}
+
+// closeListeners closes all listeners
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+}
+
+// probe sends a request to the pprof index below the path prefix of the endpoint listening on addr with
+// the client of WithHTTPClient, without client or for a unix socket with an internal client. Every response
+// is accepted, the probe only verifies the endpoint is serving within the readiness timeout.
+// The request is marked with the probe ID and not counted in the metrics, its connection is closed.
+func (p *Profiler) probe(ctx context.Context, addr net.Addr, useTLS bool) error {
+	client, scheme := p.httpClient, "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	host := probeHost(addr)
+
+	if client == nil || addr.Network() == "unix" {
+		transport := &http.Transport{}
+		client = &http.Client{Transport: transport}
+
+		if useTLS {
+			// the probe connects to its own listener, the certificate is not verified
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // nolint: gosec
+		}
+
+		if addr.Network() == "unix" {
+			host = "localhost"
+			transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", addr.String())
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.readinessTimeout())
+	defer cancel()
+
+	url := fmt.Sprintf("%s://%s%s/debug/pprof/", scheme, host, p.pathPrefix)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(probeHeader, p.probeID)
+	// the probe connection must not stay open and be tracked as active connection during the window
+	req.Close = true
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// probeHost returns the host and port to reach the listen address,
+// unspecified IPs are replaced by the loopback address
+func probeHost(addr net.Addr) string {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || !tcp.IP.IsUnspecified() {
+		return addr.String()
+	}
+
+	ip := net.IPv4(127, 0, 0, 1)
+	if tcp.IP.To4() == nil {
+		ip = net.IPv6loopback
+	}
+
+	return net.JoinHostPort(ip.String(), fmt.Sprint(tcp.Port))
+}
@@ -0,0 +1,412 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAddresses(t *testing.T) {
+	p := New(WithAddresses("127.0.0.1:6666", "[::1]:6666"))
+	assert.Equal(t, "127.0.0.1:6666", p.Address())
+	assert.Equal(t, []string{"127.0.0.1:6666", "[::1]:6666"}, p.listenAddresses())
+
+	p = New(WithAddresses("127.0.0.1:6666", "[::1]:6666"), WithAddress(":8080"))
+	assert.Equal(t, []string{":8080"}, p.listenAddresses())
+}
+
+func TestMultipleAddresses(t *testing.T) {
+	c := newFakeClock()
+	addresses := []string{freeAddress(t), freeAddress(t)}
+	bound := make(chan []string, 1)
+	hook := make(shutdownHook, 1)
+
+	p := New(
+		WithSignal(testSignal),
+		WithAddresses(addresses...),
+		WithOnListen(func(addrs []string) {
+			bound <- addrs
+		}),
+		WithClock(c),
+		WithHooks(hook),
+	)
+
+	timer := openWindow(t, p, c)
+	assert.Equal(t, addresses, <-bound)
+
+	for _, addr := range addresses {
+		resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", addr))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	timer.fire()
+	<-hook
+
+	for _, addr := range addresses {
+		_, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", addr))
+		assert.Error(t, err)
+	}
+
+	p.Stop()
+}
+
+func TestWithReadinessProbe(t *testing.T) {
+	p := New(WithReadinessProbe(true))
+	assert.True(t, p.readinessProbe)
+}
+
+func TestReadinessProbe(t *testing.T) {
+	c := newFakeClock()
+	requests := make(chan string, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithReadinessProbe(true),
+		WithPathPrefix("/admin"),
+		WithHandlerWrapper(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case requests <- r.URL.Path:
+				default:
+				}
+				next.ServeHTTP(w, r)
+			})
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+
+	// the probe is done before the window is reported open, below the path prefix
+	select {
+	case path := <-requests:
+		assert.Equal(t, "/debug/pprof/", path)
+	default:
+		t.Fatal("no readiness probe")
+	}
+
+	// the probe is not a request of a client
+	assert.Equal(t, int64(0), p.Metrics().Requests)
+	assert.Equal(t, int64(0), p.Metrics().BytesServed)
+
+	// no connection of the probe remains
+	assert.Eventually(t, func() bool {
+		return p.Metrics().ActiveConnections == 0 && len(p.ActiveConnections()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestProbeHost(t *testing.T) {
+	tt := []struct {
+		addr     net.Addr
+		expected string
+	}{
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 6666}, "127.0.0.1:6666"},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 6666}, "[::1]:6666"},
+		{&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 6666}, "10.0.0.1:6666"},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, probeHost(tc.addr))
+	}
+}
+
+func TestWindowOpenedEvent(t *testing.T) {
+	c := newFakeClock()
+	args := make(chan []interface{}, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, a ...interface{}) {
+			if code == CodeWindowOpened {
+				args <- a
+			}
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+	a := <-args
+	require.Len(t, a, 6)
+	assert.Equal(t, []interface{}{"gomaxprocs", runtime.GOMAXPROCS(0), "numcpu", runtime.NumCPU()}, a[2:])
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithPortRange(t *testing.T) {
+	// occupy the first port of the range
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	low := l.Addr().(*net.TCPAddr).Port
+	c := newFakeClock()
+	p, err := NewWithError(
+		WithSignal(testSignal),
+		WithAddress("127.0.0.1:0"),
+		WithPortRange(low, low+10),
+		WithClock(c),
+	)
+	require.NoError(t, err)
+
+	timer := openWindow(t, p, c)
+
+	_, port, err := net.SplitHostPort(p.Address())
+	require.NoError(t, err)
+	assert.NotEqual(t, strconv.Itoa(low), port)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", p.Address()))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithPortRangeInvalid(t *testing.T) {
+	_, err := NewWithError(WithPortRange(7000, 6000))
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+}
+
+func TestWithPortRangeExhausted(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	codes := make(chan EventCode, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress("127.0.0.1:0"),
+		WithPortRange(port, port),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, _ ...interface{}) {
+			if code == CodeBindFailed {
+				codes <- code
+			}
+		}),
+	)
+
+	p.Start()
+	<-p.Started()
+	sendSignal(t, p)
+
+	assert.Equal(t, CodeBindFailed, <-codes)
+	p.Stop()
+}
+
+func TestPortRangeBindError(t *testing.T) {
+	errControl := errors.New("control failed")
+	attempts := 0
+	p := New(
+		WithAddress("127.0.0.1:0"),
+		WithPortRange(7000, 7010),
+		WithListenConfig(net.ListenConfig{
+			Control: func(string, string, syscall.RawConn) error {
+				attempts++
+				return errControl
+			},
+		}),
+	)
+
+	// only an address in use continues with the next port
+	_, err := p.bindPortRange(context.Background())
+	assert.True(t, errors.Is(err, errControl))
+	assert.Equal(t, 1, attempts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = p.bindPortRange(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWithBindRetry(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	retries := make(chan interface{}, 10)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(l.Addr().String()),
+		WithBindRetry(10, 20*time.Millisecond),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeBindRetry {
+				retries <- args[1]
+			}
+		}),
+	)
+
+	open := p.WindowOpen()
+
+	p.Start()
+	<-p.Started()
+	sendSignal(t, p)
+
+	assert.Equal(t, 1, <-retries)
+	// release the address, the next attempt succeeds
+	require.NoError(t, l.Close())
+
+	<-open
+	p.Stop()
+}
+
+func TestWithStartTimeout(t *testing.T) {
+	release := make(chan struct{})
+	errs := make(chan error, 1)
+	hook := make(shutdownHook, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithStartTimeout(50*time.Millisecond),
+		WithHooks(hook),
+		WithListenConfig(net.ListenConfig{
+			Control: func(string, string, syscall.RawConn) error {
+				<-release // hanging bind
+				return nil
+			},
+		}),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeBindFailed {
+				errs <- args[1].(error)
+			}
+		}),
+	)
+
+	p.Start()
+	<-p.Started()
+	sendSignal(t, p)
+
+	assert.True(t, errors.Is(<-errs, ErrStartTimeout))
+	<-hook
+
+	close(release)
+	p.Stop()
+}
+
+func TestWithListenNetwork(t *testing.T) {
+	p := New()
+	assert.Equal(t, "tcp", p.listenNetwork())
+
+	_, err := NewWithError(WithListenNetwork("udp"))
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+
+	dir, err := ioutil.TempDir("", "profiler")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "pprof.sock")
+	c := newFakeClock()
+	p, err = NewWithError(
+		WithSignal(testSignal),
+		WithListenNetwork("unix"),
+		WithAddress(socket),
+		WithReadinessProbe(true),
+		WithClock(c),
+	)
+	require.NoError(t, err)
+
+	timer := openWindow(t, p, c)
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	resp, err := client.Get("http://localhost/debug/pprof/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithListenBacklog(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithListenBacklog(1024),
+	)
+
+	timer := openWindow(t, p, c)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+
+	// the backlog can not be set on a closed listener
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	assert.Error(t, setBacklog(l, 16))
+}
+
+func TestWithReadyTimeout(t *testing.T) {
+	assert.Equal(t, defaultReadyTimeout, New().readinessTimeout())
+
+	release := make(chan struct{})
+	defer close(release)
+
+	failed := make(chan interface{}, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithReadinessProbe(true),
+		WithReadyTimeout(50*time.Millisecond),
+		// the endpoint accepts the connection of the probe but never answers
+		WithHandlerWrapper(func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				<-release
+			})
+		}),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeEndpointFailed {
+				failed <- args[1]
+			}
+		}),
+	)
+
+	p.Start()
+	<-p.Started()
+	assert.NoError(t, p.Trigger(context.Background()))
+
+	select {
+	case err := <-failed:
+		assert.Contains(t, fmt.Sprint(err), "readiness probe failed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("readiness probe did not time out")
+	}
+
+	p.Stop()
+}
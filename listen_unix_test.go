@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package profiler
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithListenConfig(t *testing.T) {
+	c := newFakeClock()
+	controlled := make(chan string, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithListenConfig(net.ListenConfig{
+			Control: func(network, address string, rc syscall.RawConn) error {
+				var serr error
+				err := rc.Control(func(fd uintptr) {
+					serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+				})
+				controlled <- address
+
+				if err != nil {
+					return err
+				}
+
+				return serr
+			},
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+	assert.Equal(t, p.Address(), <-controlled)
+
+	timer.fire()
+	p.Stop()
+}
@@ -0,0 +1,190 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// fields of the profile.proto message of the pprof format
+const (
+	profileStringTable = 6
+	profileComment     = 13
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errInvalidProfile is returned if a profile is not in the pprof format
+var errInvalidProfile = errors.New("invalid pprof profile") // nolint: gochecknoglobals
+
+// WithCaptureMetadata adds metadata (e.g. host name, pod name, git revision) to the profiles captured by
+// WithAutoCapture, e.g. to identify the profiles collected from a fleet. The values are part of the name of the
+// stored profile, the pairs are embedded as comments of the profile (see go tool pprof -comments).
+func WithCaptureMetadata(md map[string]string) Opt {
+	return func(p *Profiler) {
+		p.captureMetadata = make(map[string]string, len(md))
+		for k, v := range md {
+			p.captureMetadata[k] = v
+		}
+	}
+}
+
+// metadataKeys returns the sorted keys of the capture metadata
+func (p *Profiler) metadataKeys() []string {
+	keys := make([]string, 0, len(p.captureMetadata))
+	for k := range p.captureMetadata {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// metadataName returns the values of the capture metadata sorted by key for the name of a profile,
+// characters which are not safe in a file name are replaced
+func (p *Profiler) metadataName() string {
+	values := make([]string, 0, len(p.captureMetadata))
+
+	for _, k := range p.metadataKeys() {
+		values = append(values, strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' {
+				return r
+			}
+
+			return '_'
+		}, p.captureMetadata[k]))
+	}
+
+	return strings.Join(values, "-")
+}
+
+// metadataComments returns the capture metadata as comments of a profile
+func (p *Profiler) metadataComments() []string {
+	comments := make([]string, 0, len(p.captureMetadata))
+	for _, k := range p.metadataKeys() {
+		comments = append(comments, k+"="+p.captureMetadata[k])
+	}
+
+	return comments
+}
+
+// addComments adds the comments to the profile in the (gzipped) pprof format
+//
+// The strings are appended to the string table of the profile message, repeated fields of a
+// protobuf message may be continued at the end of the message.
+func addComments(data []byte, comments []string) ([]byte, error) {
+	gzipped := bytes.HasPrefix(data, []byte{0x1f, 0x8b})
+
+	if gzipped {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	strs := 0
+
+	if err := scanFields(data, func(field, wire, _ uint64, _ []byte) {
+		if field == profileStringTable && wire == wireBytes {
+			strs++
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	b := append([]byte(nil), data...)
+
+	for i, c := range comments {
+		b = appendUvarint(b, profileStringTable<<3|wireBytes)
+		b = appendUvarint(b, uint64(len(c)))
+		b = append(b, c...)
+		b = appendUvarint(b, profileComment<<3|wireVarint)
+		b = appendUvarint(b, uint64(strs+i))
+	}
+
+	if !gzipped {
+		return b, nil
+	}
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// appendUvarint appends the varint encoded value to b
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// scanFields calls f for every field of the protobuf message with the value of a varint field
+// or the content of a length-delimited field
+func scanFields(b []byte, f func(field, wire, varint uint64, value []byte)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errInvalidProfile
+		}
+
+		b = b[n:]
+
+		var (
+			varint uint64
+			value  []byte
+		)
+
+		switch key & 7 {
+		case wireVarint:
+			if varint, n = binary.Uvarint(b); n <= 0 {
+				return errInvalidProfile
+			}
+		case wireFixed64:
+			n = 8
+		case wireFixed32:
+			n = 4
+		case wireBytes:
+			size, l := binary.Uvarint(b)
+			if l <= 0 || size > uint64(len(b)-l) {
+				return errInvalidProfile
+			}
+
+			value = b[l : l+int(size)]
+			n = l + int(size)
+		default:
+			return errInvalidProfile
+		}
+
+		if n > len(b) {
+			return errInvalidProfile
+		}
+
+		f(key>>3, key&7, varint, value)
+		b = b[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// profileComments returns the comments of the gzipped profile
+func profileComments(t *testing.T, data []byte) []string {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	data, err = ioutil.ReadAll(zr)
+	require.NoError(t, err)
+
+	var (
+		strs     []string
+		comments []uint64
+	)
+
+	require.NoError(t, scanFields(data, func(field, _, varint uint64, value []byte) {
+		switch field {
+		case profileStringTable:
+			strs = append(strs, string(value))
+		case profileComment:
+			comments = append(comments, varint)
+		}
+	}))
+
+	result := make([]string, 0, len(comments))
+	for _, i := range comments {
+		result = append(result, strs[i])
+	}
+
+	return result
+}
+
+func TestAddComments(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 0))
+
+	data, err := addComments(buf.Bytes(), []string{"host=h1", "rev=abc"})
+	require.NoError(t, err)
+	assert.Equal(t, gzipMagic, data[:2])
+	assert.Equal(t, []string{"host=h1", "rev=abc"}, profileComments(t, data))
+
+	_, err = addComments([]byte{0xff}, []string{"host=h1"})
+	assert.Equal(t, errInvalidProfile, err)
+}
+
+func TestWithCaptureMetadata(t *testing.T) {
+	md := map[string]string{"rev": "abc", "host": "h1", "pod": "api-7/x"}
+	type stored struct {
+		name string
+		data []byte
+	}
+
+	profiles := make(chan stored, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithAutoCapture("goroutine", 0, 0, ""),
+		WithCaptureMetadata(md),
+		WithProfileStorage(ProfileStorageFunc(func(name string, r io.Reader) error {
+			data, err := ioutil.ReadAll(r)
+			profiles <- stored{name: name, data: data}
+
+			return err
+		})),
+	)
+
+	// the metadata is copied
+	md["host"] = "h2"
+
+	p.Start()
+	<-p.Started()
+	assert.NoError(t, p.Trigger(context.Background()))
+
+	profile := <-profiles
+	p.Stop()
+
+	assert.True(t, strings.HasPrefix(profile.name, "goroutine-h1-api_7_x-abc-"), profile.name)
+	assert.Equal(t, []string{"host=h1", "pod=api-7/x", "rev=abc"}, profileComments(t, profile.data))
+}
@@ -0,0 +1,195 @@
+package profiler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// connsPath is the route of the active connections
+const connsPath = "/debug/conns"
+
+// Metrics are the metrics of the pprof endpoint
+type Metrics struct {
+	// BytesServed is the number of bytes written to responses of the pprof endpoint
+	BytesServed int64 `json:"bytesServed"`
+	// ActiveConnections is the number of open connections to the pprof endpoint
+	ActiveConnections int64 `json:"activeConnections"`
+	// Requests is the number of requests served by the pprof endpoint
+	Requests int64 `json:"requests"`
+}
+
+// metrics are updated atomically, the active connections are protected by the mutex
+type metrics struct {
+	bytesServed       int64
+	activeConnections int64
+	requests          int64
+
+	sync.Mutex
+	conns map[net.Conn]string
+}
+
+// WithMetricsExpvar publishes the Metrics as expvar variable with the given name, see WithExpvarFunc
+func WithMetricsExpvar(name string) Opt {
+	return func(p *Profiler) {
+		p.expvars = append(p.expvars, expvarFunc{name: name, f: func() interface{} {
+			return p.Metrics()
+		}})
+	}
+}
+
+// Metrics returns the current metrics of the pprof endpoint
+func (p *Profiler) Metrics() Metrics {
+	return Metrics{
+		BytesServed:       atomic.LoadInt64(&p.metrics.bytesServed),
+		ActiveConnections: atomic.LoadInt64(&p.metrics.activeConnections),
+		Requests:          atomic.LoadInt64(&p.metrics.requests),
+	}
+}
+
+// WithConnectionsRoute enables the /debug/conns route which lists the remote addresses
+// of the active connections, see ActiveConnections
+func WithConnectionsRoute(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.connsRoute = enabled
+	}
+}
+
+// ActiveConnections returns the sorted remote addresses of the active connections to the pprof endpoint
+func (p *Profiler) ActiveConnections() []string {
+	p.metrics.Lock()
+	defer p.metrics.Unlock()
+
+	addrs := make([]string, 0, len(p.metrics.conns))
+	for _, addr := range p.metrics.conns {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+// serveConns lists the remote addresses of the active connections
+func (p *Profiler) serveConns(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	for _, addr := range p.ActiveConnections() {
+		fmt.Fprintln(w, addr)
+	}
+}
+
+// trackConn counts and records the active connections
+func (p *Profiler) trackConn(c net.Conn, s http.ConnState) {
+	switch s {
+	case http.StateNew:
+		atomic.AddInt64(&p.metrics.activeConnections, 1)
+
+		p.metrics.Lock()
+		if p.metrics.conns == nil {
+			p.metrics.conns = map[net.Conn]string{}
+		}
+
+		p.metrics.conns[c] = c.RemoteAddr().String()
+		p.metrics.Unlock()
+	case http.StateClosed, http.StateHijacked:
+		atomic.AddInt64(&p.metrics.activeConnections, -1)
+
+		p.metrics.Lock()
+		delete(p.metrics.conns, c)
+		p.metrics.Unlock()
+	}
+}
+
+// countBytes counts the requests and the bytes written to the responses and reports failed writes
+//
+// A client disconnecting during the response (e.g. while a profile is streamed) is not an error
+// of the pprof endpoint, therefore it is reported as DebugEvent.
+func (p *Profiler) countBytes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(probeHeader) == p.probeID {
+			// the readiness probe is not a request of a client
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		atomic.AddInt64(&p.metrics.requests, 1)
+
+		mw := &metricsWriter{ResponseWriter: w, n: &p.metrics.bytesServed}
+
+		next.ServeHTTP(mw, r)
+
+		switch {
+		case mw.err == nil:
+		case isClientDisconnect(mw.err):
+			p.evt(DebugEvent, CodeClientDisconnected, MsgClientDisconnected, "path", r.URL.Path, "error", mw.err)
+		default:
+			p.evt(WarnEvent, CodeWriteFailed, MsgWriteFailed, "path", r.URL.Path, "error", mw.err)
+		}
+	})
+}
+
+// isClientDisconnect reports whether the error is caused by a closed client connection
+func isClientDisconnect(err error) bool {
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// net.ErrClosed is not available before go1.16
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// metricsWriter adds the bytes written to the response to a counter
+// and records the first write error
+type metricsWriter struct {
+	http.ResponseWriter
+	n   *int64
+	err error
+}
+
+func (w *metricsWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	atomic.AddInt64(w.n, int64(n))
+
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does
+func (w *metricsWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does
+func (w *metricsWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// Push implements http.Pusher if the underlying writer does
+func (w *metricsWriter) Push(target string, opts *http.PushOptions) error {
+	return push(w.ResponseWriter, target, opts)
+}
+
+// ReadFrom implements io.ReaderFrom with the underlying writer, the bytes are counted
+func (w *metricsWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := readFrom(w.ResponseWriter, r)
+	atomic.AddInt64(w.n, n)
+
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return n, err
+}
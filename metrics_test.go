@@ -0,0 +1,128 @@
+package profiler
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+	)
+
+	timer := openWindow(t, p, c)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	m := p.Metrics()
+	assert.Equal(t, int64(len(b)), m.BytesServed)
+	assert.Equal(t, int64(1), m.ActiveConnections)
+
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+
+	assert.Eventually(t, func() bool {
+		return p.Metrics().ActiveConnections == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWithMetricsExpvar(t *testing.T) {
+	name := fmt.Sprintf("profiler_metrics_%d", time.Now().UnixNano())
+	p := New(WithMetricsExpvar(name))
+
+	v := expvar.Get(name)
+	require.NotNil(t, v)
+	assert.Equal(t, p.Metrics(), v.(expvar.Func).Value())
+}
+
+// failingWriter fails every write with the error
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestClientDisconnected(t *testing.T) {
+	tt := []struct {
+		err      error
+		expected EventType
+	}{
+		{&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, DebugEvent},
+		{&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.ECONNRESET)}, DebugEvent},
+		{errors.New("disk full"), WarnEvent},
+	}
+
+	for _, tc := range tt {
+		types := make(chan EventType, 1)
+		p := New(WithEventHandler(func(t EventType, _ string, _ ...interface{}) {
+			types <- t
+		}))
+
+		w := failingWriter{ResponseRecorder: httptest.NewRecorder(), err: tc.err}
+		p.mux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+
+		assert.Equal(t, tc.expected, <-types, tc.err)
+	}
+}
+
+func TestActiveConnections(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithConnectionsRoute(true),
+	)
+
+	timer := openWindow(t, p, c)
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		conns := p.ActiveConnections()
+		return len(conns) == 1 && conns[0] == conn.LocalAddr().String()
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = fmt.Fprintf(conn, "GET /debug/conns HTTP/1.0\r\n\r\n")
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), conn.LocalAddr().String())
+
+	_ = conn.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(p.ActiveConnections()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	timer.fire()
+	p.Stop()
+}
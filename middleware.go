@@ -0,0 +1,373 @@
+package profiler
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WithResponseHeaders sets the given headers on every response of the pprof endpoint
+func WithResponseHeaders(h http.Header) Opt {
+	return func(p *Profiler) {
+		if p.headers == nil {
+			p.headers = http.Header{}
+		}
+
+		for k, v := range h {
+			p.headers[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+// WithCORS enables CORS for the given origins on the pprof endpoint
+//
+// An origin may contain wildcards as supported by path.Match (e.g. "https://*.example.com"),
+// the origin "*" allows all origins.
+func WithCORS(allowedOrigins []string) Opt {
+	return func(p *Profiler) {
+		p.corsOrigins = append(p.corsOrigins, allowedOrigins...)
+	}
+}
+
+// WithHandlerWrapper adds a middleware to the handler of the pprof endpoint
+//
+// Multiple wrappers are applied in order, the first wrapper being the outermost.
+// The wrappers are applied inside of the built-in middlewares. A request passes the middlewares
+// in the following order before reaching the pprof handlers:
+//
+//	recover → client IP → request ID → access log → closing notice → compression → response headers →
+//	security headers → CORS → auth → wrappers → profile quota → request limit → route timeouts →
+//	profile buffer → handlers
+func WithHandlerWrapper(w func(http.Handler) http.Handler) Opt {
+	return func(p *Profiler) {
+		p.wrappers = append(p.wrappers, w)
+	}
+}
+
+// WithRecover recovers panics of all handlers of the pprof endpoint, the request is answered with
+// 500 Internal Server Error and an ErrorEvent is emitted. The handlers of WithExtraHandler are always recovered.
+func WithRecover(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.recover = enabled
+	}
+}
+
+// nolint: gochecknoglobals
+// streamingRoutes are the routes which stream their response for a requested duration,
+// they are not subject to the route timeouts
+var streamingRoutes = map[string]bool{
+	"/debug/pprof/profile": true,
+	"/debug/pprof/trace":   true,
+	bundlePath:             true,
+}
+
+// WithRouteTimeouts sets the timeouts of the routes of the pprof endpoint with http.TimeoutHandler,
+// the keys are paths which may contain wildcards as supported by path.Match (e.g. "/debug/pprof/*").
+// The streaming routes (/debug/pprof/profile, /debug/pprof/trace, /debug/bundle and requests
+// with a seconds parameter) are not subject to the timeouts.
+func WithRouteTimeouts(timeouts map[string]time.Duration) Opt {
+	return func(p *Profiler) {
+		if p.routeTimeouts == nil {
+			p.routeTimeouts = map[string]time.Duration{}
+		}
+
+		for k, v := range timeouts {
+			p.routeTimeouts[k] = v
+		}
+	}
+}
+
+// WithAccessLog emits an InfoEvent for every request to the pprof endpoint,
+// with the method, path, status, written bytes and duration of the request
+func WithAccessLog(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.accessLog = enabled
+	}
+}
+
+// WithAuthFunc sets a function to authorize the requests to the pprof endpoint,
+// unauthorized requests are answered with 401 Unauthorized. CORS preflight requests
+// are answered before the authorization.
+func WithAuthFunc(f func(r *http.Request) bool) Opt {
+	return func(p *Profiler) {
+		p.authFunc = f
+	}
+}
+
+// middleware wraps the handler with the configured middlewares, see WithHandlerWrapper for the order
+func (p *Profiler) middleware(next http.Handler) http.Handler {
+	if p.bufferProfiles {
+		next = bufferProfiles(maxBufferedProfile, next)
+	}
+
+	if len(p.routeTimeouts) > 0 {
+		next = routeTimeouts(p.routeTimeouts, next)
+	}
+
+	if p.maxRequests > 0 {
+		next = p.limitRequests(next)
+	}
+
+	if p.quota > 0 && p.quotaReset > 0 {
+		next = p.profileQuota(next)
+	}
+
+	for i := len(p.wrappers) - 1; i >= 0; i-- {
+		next = p.wrappers[i](next)
+	}
+
+	if p.authFunc != nil {
+		next = auth(p.authFunc, next)
+	}
+
+	if len(p.corsOrigins) > 0 {
+		next = cors(p.corsOrigins, next)
+	}
+
+	if p.securityHeaders {
+		next = securityHeaders(next)
+	}
+
+	if len(p.headers) > 0 {
+		next = responseHeaders(p.headers, next)
+	}
+
+	if p.compression {
+		next = p.compress(next)
+	}
+
+	if p.clientNotice > 0 {
+		next = p.noticeClosing(next)
+	}
+
+	if p.accessLog {
+		next = p.logAccess(next)
+	}
+
+	if p.requestIDHeader != "" {
+		next = p.requestID(next)
+	}
+
+	if len(p.trustedProxies) > 0 {
+		next = p.clientIP(next)
+	}
+
+	if p.recover {
+		next = p.recoverer(next)
+	}
+
+	return next
+}
+
+// recoverer answers a panic of the handler with 500 Internal Server Error
+//
+// http.ErrAbortHandler is propagated to abort the response, see net/http.
+func (p *Profiler) recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			p.evt(ErrorEvent, CodeHandlerPanic, MsgHandlerPanic, "path", r.URL.Path, "panic", v, "stack", string(debug.Stack()))
+			serveError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeTimeouts applies the timeout of the matching route
+func routeTimeouts(timeouts map[string]time.Duration, next http.Handler) http.Handler {
+	patterns := make([]string, 0, len(timeouts))
+	for k := range timeouts {
+		patterns = append(patterns, k)
+	}
+
+	sort.Strings(patterns)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamingRoutes[r.URL.Path] || r.URL.Query().Get("seconds") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d, ok := timeouts[r.URL.Path]
+
+		for i := 0; !ok && i < len(patterns); i++ {
+			if match, err := path.Match(patterns[i], r.URL.Path); err == nil && match {
+				d, ok = timeouts[patterns[i]], true
+			}
+		}
+
+		if !ok || d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.TimeoutHandler(next, d, "pprof handler timeout").ServeHTTP(w, r)
+	})
+}
+
+// logAccess emits an event for every request
+func (p *Profiler) logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := p.clock.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sw, r)
+
+		args := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"bytes", sw.n,
+			"duration", p.clock.Now().Sub(start),
+			"remote", ClientIP(r),
+		}
+
+		if id := RequestID(r); id != "" {
+			args = append(args, "request_id", id)
+		}
+
+		p.evt(InfoEvent, CodeAccessLog, MsgAccessLog, args...)
+	})
+}
+
+// auth answers unauthorized requests with 401 Unauthorized
+func auth(authorized func(*http.Request) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			serveError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusWriter records the status and counts the bytes written to the response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	n           int64
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// Push implements http.Pusher if the underlying writer does
+func (w *statusWriter) Push(target string, opts *http.PushOptions) error {
+	return push(w.ResponseWriter, target, opts)
+}
+
+// ReadFrom implements io.ReaderFrom with the underlying writer, the bytes are counted
+func (w *statusWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.wroteHeader = true
+	n, err := readFrom(w.ResponseWriter, r)
+	w.n += n
+
+	return n, err
+}
+
+// responseHeaders sets the headers before the request is handled
+func responseHeaders(h http.Header, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range h {
+			w.Header()[k] = v
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cors sets the CORS headers for allowed origins and answers preflight requests
+//
+// Websocket upgrade requests are passed through untouched apart from the CORS headers.
+func cors(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		if !originAllowed(origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+
+			if h := r.Header.Get("Access-Control-Request-Headers"); h != "" {
+				w.Header().Set("Access-Control-Allow-Headers", h)
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originAllowed reports whether the origin matches one of the allowed origins
+func originAllowed(origins []string, origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, o := range origins {
+		if o == "*" {
+			return true
+		}
+
+		if ok, err := path.Match(strings.ToLower(o), origin); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
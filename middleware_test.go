@@ -0,0 +1,219 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithResponseHeaders(t *testing.T) {
+	p := New(WithResponseHeaders(http.Header{
+		"cache-control": []string{"no-store"},
+	}))
+	assert.Equal(t, http.Header{"Cache-Control": []string{"no-store"}}, p.headers)
+}
+
+func TestResponseHeaders(t *testing.T) {
+	p := New(WithResponseHeaders(http.Header{
+		"Cache-Control":          []string{"no-store"},
+		"X-Debug-Token-Consumed": []string{"1"},
+	}))
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "1", rec.Header().Get("X-Debug-Token-Consumed"))
+}
+
+func TestWithCORS(t *testing.T) {
+	origins := []string{"https://example.com"}
+	p := New(WithCORS(origins))
+	assert.Equal(t, origins, p.corsOrigins)
+}
+
+func TestCORS(t *testing.T) {
+	p := New(WithCORS([]string{"https://example.com", "http://*.dev.local:8080"}))
+	h := p.mux()
+
+	tt := []struct {
+		origin string
+		method string
+		code   int
+		allow  string
+	}{
+		{"https://example.com", http.MethodGet, http.StatusOK, "https://example.com"},
+		{"http://app.dev.local:8080", http.MethodGet, http.StatusOK, "http://app.dev.local:8080"},
+		{"https://example.com", http.MethodOptions, http.StatusNoContent, "https://example.com"},
+		{"https://evil.com", http.MethodGet, http.StatusOK, ""},
+		{"", http.MethodGet, http.StatusOK, ""},
+	}
+
+	for _, tc := range tt {
+		r := httptest.NewRequest(tc.method, "/debug/pprof/", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+
+		if tc.method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		assert.Equal(t, tc.code, rec.Code, tc.origin)
+		assert.Equal(t, tc.allow, rec.Header().Get("Access-Control-Allow-Origin"), tc.origin)
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	assert.True(t, originAllowed([]string{"*"}, "https://any.where"))
+	assert.False(t, originAllowed([]string{"https://*.example.com"}, "https://example.com"))
+	assert.False(t, originAllowed([]string{"[invalid"}, "[invalid"))
+}
+
+func TestWithHandlerWrapper(t *testing.T) {
+	order := []string{}
+	wrapper := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	p := New(
+		WithHandlerWrapper(wrapper("first")),
+		WithHandlerWrapper(wrapper("second")),
+		WithHandlerWrapper(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// built-in middlewares are applied before the wrappers
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+				next.ServeHTTP(w, r)
+			})
+		}),
+		WithResponseHeaders(http.Header{"Cache-Control": []string{"no-store"}}),
+	)
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"first", "second"}, order)
+}
+
+func TestWithRecover(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(
+		WithEventHandler(r.handle),
+		WithRecover(true),
+		WithHandlerWrapper(func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic("broken wrapper")
+			})
+		}),
+	)
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, 1, r.count(MsgHandlerPanic))
+
+	// http.ErrAbortHandler aborts the response
+	p = New(
+		WithRecover(true),
+		WithHandlerWrapper(func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic(http.ErrAbortHandler)
+			})
+		}),
+	)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		p.mux().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	})
+}
+
+func TestWithRouteTimeouts(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}
+
+	p := New(
+		WithRouteTimeouts(map[string]time.Duration{
+			"/debug/slow/*": 10 * time.Millisecond,
+		}),
+		WithExtraHandler("/debug/slow/", http.HandlerFunc(slow)),
+	)
+	h := p.mux()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/slow/route", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	// routes without timeout are not affected
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRouteTimeoutsStreaming(t *testing.T) {
+	p := New(WithRouteTimeouts(map[string]time.Duration{
+		"/debug/pprof/*": time.Millisecond,
+	}))
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/trace?seconds=0.1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var logged []interface{}
+
+	p := New(
+		WithAccessLog(true),
+		WithCORS([]string{"https://example.com"}),
+		WithAuthFunc(func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "secret"
+		}),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeAccessLog {
+				logged = args
+			}
+		}),
+	)
+	h := p.mux()
+
+	// an unauthorized request is access logged with the status of the auth middleware
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.NotNil(t, logged)
+	assert.Equal(t, []interface{}{"method", http.MethodGet, "path", "/debug/pprof/", "status", http.StatusUnauthorized}, logged[:6])
+
+	// CORS preflight requests are answered before the authorization
+	req := httptest.NewRequest(http.MethodOptions, "/debug/pprof/", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, http.StatusNoContent, logged[5])
+
+	req = httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	req.Header.Set("Authorization", "secret")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, logged[5])
+	assert.Equal(t, int64(rec.Body.Len()), logged[7])
+}
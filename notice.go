@@ -0,0 +1,46 @@
+package profiler
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// closedNotice is the body of the response to a request while the window closes
+const closedNotice = `{"error":"profiler window closed"}` + "\n"
+
+// WithGracefulClientNotice keeps the pprof endpoint serving for d when the window closes and answers the
+// new requests with 503 Service Unavailable and a JSON body instead of resetting the connections,
+// e.g. to let a tool display a message. The requests in-flight are not affected.
+func WithGracefulClientNotice(d time.Duration) Opt {
+	return func(p *Profiler) {
+		p.clientNotice = d
+	}
+}
+
+// noticeClosing answers the requests with a JSON 503 while the window closes
+func (p *Profiler) noticeClosing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&p.closing) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(closedNotice))
+	})
+}
+
+// announceClosing answers the new requests with the closed notice for the notice period
+func (p *Profiler) announceClosing() {
+	if p.clientNotice <= 0 {
+		return
+	}
+
+	atomic.StoreInt32(&p.closing, 1)
+
+	timer := p.clock.NewTimer(p.clientNotice)
+	<-timer.C()
+}
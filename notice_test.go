@@ -0,0 +1,51 @@
+package profiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithGracefulClientNotice(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithGracefulClientNotice(time.Second),
+	)
+
+	timer := openWindow(t, p, c)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the window closes, the endpoint serves the notice until the notice timer fires
+	timer.fire()
+	notice := <-c.timers
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+	require.NoError(t, err)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"profiler window closed"}`, string(body))
+
+	notice.fire()
+	p.Stop()
+
+	// the notice is reset for the next activation
+	assert.Equal(t, int32(0), p.closing)
+}
@@ -0,0 +1,121 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+)
+
+// cpuProfileSeconds is the default duration of the CPU profile, see net/http/pprof.Profile
+const cpuProfileSeconds = 30
+
+// cpuProfiling serializes the CPU profiles started by the package,
+// the runtime only allows one active CPU profile at a time
+var cpuProfiling = make(chan struct{}, 1) // nolint: gochecknoglobals
+
+// acquireCPUProfile reports whether no other CPU profile of the package is in progress,
+// releaseCPUProfile has to be called after the profile is stopped
+func acquireCPUProfile() bool {
+	select {
+	case cpuProfiling <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitCPUProfile waits until no other CPU profile of the package is in progress like acquireCPUProfile,
+// it returns false if the context is done before
+func waitCPUProfile(ctx context.Context) bool {
+	select {
+	case cpuProfiling <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseCPUProfile allows the next CPU profile to start
+func releaseCPUProfile() {
+	<-cpuProfiling
+}
+
+// cpuProfile returns the handler for the CPU profile which emits events when the profile starts and finishes
+func (p *Profiler) cpuProfile() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sec, err := strconv.ParseInt(r.FormValue("seconds"), 10, 64)
+		if sec <= 0 || err != nil {
+			sec = cpuProfileSeconds
+		}
+
+		if !acquireCPUProfile() {
+			serveError(w, http.StatusConflict, ErrCPUProfileInProgress.Error())
+			return
+		}
+
+		defer releaseCPUProfile()
+
+		p.evt(InfoEvent, CodeProfileStarted, MsgCPUProfileStarted, "seconds", sec)
+
+		cw := &countingWriter{ResponseWriter: w}
+		pprof.Profile(cw, r)
+
+		p.evt(InfoEvent, CodeProfileFinished, MsgCPUProfileFinished, "bytes", cw.n)
+	})
+}
+
+// WithTraceDefaults sets the duration of the execution trace on /debug/pprof/trace if the seconds
+// parameter is omitted (default: 1s) and rejects requests exceeding maxSeconds. Zero keeps the default
+// respectively disables the limit.
+func WithTraceDefaults(defaultSeconds, maxSeconds int) Opt {
+	return func(p *Profiler) {
+		p.traceDefault = defaultSeconds
+		p.traceMax = maxSeconds
+	}
+}
+
+// trace returns the handler for the execution trace which applies the trace defaults
+func (p *Profiler) trace() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		sec, err := strconv.ParseFloat(q.Get("seconds"), 64)
+		if sec <= 0 || err != nil {
+			if p.traceDefault <= 0 {
+				pprof.Trace(w, r)
+				return
+			}
+
+			sec = float64(p.traceDefault)
+			q.Set("seconds", strconv.Itoa(p.traceDefault))
+
+			u := *r.URL
+			u.RawQuery = q.Encode()
+
+			r = r.WithContext(r.Context())
+			r.URL = &u
+		}
+
+		if p.traceMax > 0 && sec > float64(p.traceMax) {
+			serveError(w, http.StatusBadRequest, fmt.Sprintf("trace duration exceeds the maximum of %ds", p.traceMax))
+			return
+		}
+
+		pprof.Trace(w, r)
+	})
+}
+
+// countingWriter counts the bytes written to the response
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+
+	return n, err
+}
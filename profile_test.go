@@ -0,0 +1,85 @@
+package profiler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUProfileEvents(t *testing.T) {
+	type event struct {
+		code EventCode
+		args []interface{}
+	}
+
+	events := make(chan event, 2)
+	p := New(WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+		events <- event{code, args}
+	}))
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	e := <-events
+	assert.Equal(t, CodeProfileStarted, e.code)
+	assert.Equal(t, []interface{}{"seconds", int64(1)}, e.args)
+
+	e = <-events
+	assert.Equal(t, CodeProfileFinished, e.code)
+	assert.Equal(t, []interface{}{"bytes", int64(rec.Body.Len())}, e.args)
+}
+
+func TestConcurrentCPUProfile(t *testing.T) {
+	started := make(chan struct{}, 1)
+	p := New(WithEventCodeHandler(func(_ EventType, code EventCode, _ string, _ ...interface{}) {
+		if code == CodeProfileStarted {
+			started <- struct{}{}
+		}
+	}))
+	h := p.mux()
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+
+	go func() {
+		h.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=1", nil))
+		close(done)
+	}()
+
+	<-started
+
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=1", nil))
+	assert.Equal(t, http.StatusConflict, second.Code)
+
+	_, err := p.CaptureCPU(context.Background(), time.Millisecond)
+	assert.Equal(t, ErrCPUProfileInProgress, err)
+
+	<-done
+	assert.Equal(t, http.StatusOK, first.Code)
+}
+
+func TestWithTraceDefaults(t *testing.T) {
+	h := New(WithTraceDefaults(0, 2)).mux()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/trace?seconds=3", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/trace?seconds=0.1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// the default duration is subject to the limit as well
+	h = New(WithTraceDefaults(5, 2)).mux()
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/trace", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
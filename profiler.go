@@ -3,12 +3,16 @@ package profiler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	// nolint: gosec // G108: Profiling endpoint is automatically exposed on /debug/pprof
@@ -40,20 +44,144 @@ type Profiler struct {
 	signal  os.Signal
 	address string
 	timeout time.Duration
-	hooks   []Hooker
+	hooks   []HookerContext
+	bundle  bool
+	clock   Clock
+	headers http.Header
+	drain   time.Duration
 
-	stop chan struct{}
-	done chan struct{}
-	once *sync.Once
+	maxHeaderBytes int
+
+	addresses []string
+	onListen  func(addrs []string)
+
+	drainSignal     os.Signal
+	startDelay      time.Duration
+	readinessProbe  bool
+	readyTimeout    time.Duration
+	signalBuffer    int
+	shutdownOnPanic bool
+
+	listenConfig  net.ListenConfig
+	network       string
+	listenBacklog int
+	portLow       int
+	portHigh      int
+	bindRetries   int
+	bindBackoff   time.Duration
+	startTimeout  time.Duration
+
+	windowDeadline time.Time
+	windowTimeout  time.Duration
+	timeoutFunc    func() time.Duration
+	timeoutJitter  float64
+
+	handlerTTL      time.Duration
+	handlerDeadline time.Time
+
+	expvars []expvarFunc
+	cmdline []string
+
+	cmdlineRedactor func([]string) []string
+
+	traceDefault int
+	traceMax     int
+
+	trigger             chan activationRequest
+	autoTriggerCond     func() bool
+	autoTriggerInterval time.Duration
+	autoTriggerMax      int
+	autoTriggerPer      time.Duration
+
+	continuous      ContinuousConfig
+	autoCapture     *autoCapture
+	captureMetadata map[string]string
+	storage         ProfileStorage
+	clientNotice    time.Duration
+	bufferProfiles  bool
+	// closing is set while the window closes, see WithGracefulClientNotice
+	closing int32
+
+	snapshots        *snapshotRing
+	snapshotInterval time.Duration
+
+	server     *http.Server
+	tlsCert    atomic.Value
+	pathPrefix string
+	// probeID marks the requests of the readiness probe, see probeHeader
+	probeID         string
+	requestIDHeader string
+	baseContext     func(net.Listener) context.Context
+	serveContext    bool
+	connStates      []func(net.Conn, http.ConnState)
+
+	metrics    *metrics
+	connsRoute bool
+
+	stacksRoute bool
+	envRoute    bool
+
+	gcParamsRoute bool
+
+	eventHandler EventCodeHandler
+	logAttrs     []interface{}
+
+	corsOrigins []string
+	wrappers    []func(http.Handler) http.Handler
+	recover     bool
+	accessLog   bool
+	compression bool
+	encoders    []encoder
+	authFunc    func(*http.Request) bool
+
+	securityHeaders bool
+
+	trustedProxyCIDRs  []string
+	trustedProxyHeader string
+	trustedProxies     []*net.IPNet
+
+	routeTimeouts map[string]time.Duration
+
+	extraHandlers  []extraHandler
+	disabledRoutes map[string]bool
+	enabledRoutes  map[string]bool
+	muxFactory     func() http.Handler
+
+	startConflictHandler func()
+	signalOnce           bool
+	logConfigOnInit      bool
+	webhookURL           string
+	httpClient           *http.Client
+	onWindowClose        func(WindowStats)
+	shutdownCallback     func(error)
+	signalErr            error
+	ignoredErr           error
+	strict               bool
+	maxRequests          int
+	quota                int
+	quotaReset           time.Duration
+	requestLimit         chan struct{}
+
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running bool
+	started chan struct{}
+	window  chan struct{}
+	// activationReason is the reason of the current or last activation, see TriggerReason
+	activationReason string
+	// closed is closed when the pprof endpoint of the current activation is shutdown or failed to start
+	closed chan struct{}
 }
 
 // Opt are Profiler functional options
 type Opt func(*Profiler)
 
-// WithSignal sets the signal to aktivate the pprof handler
+// WithSignal sets the signal to aktivate the pprof handler, with a nil signal the pprof handler
+// is activated with Trigger only
 func WithSignal(s os.Signal) Opt {
 	return func(p *Profiler) {
 		p.signal = s
+		p.signalErr = nil
 	}
 }
 
@@ -61,6 +189,24 @@ func WithSignal(s os.Signal) Opt {
 func WithAddress(address string) Opt {
 	return func(p *Profiler) {
 		p.address = address
+		p.addresses = nil
+	}
+}
+
+// WithDrainSignal sets a signal to shutdown an open pprof endpoint before the timeout,
+// the PostShutdown hooks are executed and the endpoint can be activated again with the signal.
+// The drain signal is ignored while the endpoint is not open.
+func WithDrainSignal(s os.Signal) Opt {
+	return func(p *Profiler) {
+		p.drainSignal = s
+	}
+}
+
+// WithStartDelay delays the start of the pprof endpoint after an activation, the activation
+// is canceled if the drain signal (see WithDrainSignal) is received during the delay
+func WithStartDelay(d time.Duration) Opt {
+	return func(p *Profiler) {
+		p.startDelay = d
 	}
 }
 
@@ -71,148 +217,863 @@ func WithTimeout(timeout time.Duration) Opt {
 	}
 }
 
+// WithStrict makes New panic if the configuration is invalid (see NewWithError) instead of emitting events
+// and continuing with the defaults, e.g. to fail fast in CI. The panic value is an error wrapping the validation error.
+// In strict mode a termination signal (SIGINT, SIGTERM) as activation signal is rejected with ErrTerminationSignal,
+// otherwise New emits a WarnEvent. A nil signal (WithSignal(nil), the default on windows) activates the pprof
+// endpoint with Trigger only and passes both signal checks.
+func WithStrict(strict bool) Opt {
+	return func(p *Profiler) {
+		p.strict = strict
+	}
+}
+
+// WithHandlerTTL stops the signal handler after the duration since its start regardless of activity,
+// e.g. to prevent lingering signal handlers in short-lived processes. An open pprof endpoint is shutdown.
+func WithHandlerTTL(d time.Duration) Opt {
+	return func(p *Profiler) {
+		p.handlerTTL = d
+	}
+}
+
+// WithHandlerDeadline stops the signal handler at the deadline like WithHandlerTTL,
+// it takes precedence over WithHandlerTTL
+func WithHandlerDeadline(t time.Time) Opt {
+	return func(p *Profiler) {
+		p.handlerDeadline = t
+	}
+}
+
+// WithTimeoutFunc sets a function which returns the timeout of the pprof endpoint,
+// it is evaluated at every activation and takes precedence over WithTimeout.
+// The timeout of WithTimeout is used if the function returns a duration <= 0.
+func WithTimeoutFunc(f func() time.Duration) Opt {
+	return func(p *Profiler) {
+		p.timeoutFunc = f
+	}
+}
+
+// WithDrainTimeout sets the duration in-flight requests (e.g. a running CPU profile) are given
+// to complete when the pprof endpoint is shutdown. No new connections are accepted in the meantime.
+// Remaining connections are closed after the drain timeout.
+// If not set, the timeout of the pprof endpoint is used.
+func WithDrainTimeout(d time.Duration) Opt {
+	return func(p *Profiler) {
+		p.drain = d
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of the request headers of the pprof endpoint,
+// see http.Server.MaxHeaderBytes
+func WithMaxHeaderBytes(n int) Opt {
+	return func(p *Profiler) {
+		p.maxHeaderBytes = n
+	}
+}
+
+// WithHTTPServer sets a pre-configured server for the pprof endpoint
+//
+// The settings of the server (e.g. timeouts, TLSConfig, ConnState, ErrorLog) are used for the pprof endpoint,
+// Addr and Handler are set by the Profiler. If TLSConfig is set, the endpoint is served with TLS.
+// Rotated certificates are picked up with the GetCertificate callback of the TLSConfig or with ReloadTLS.
+// Without ErrorLog the errors of the server are emitted as events.
+func WithHTTPServer(srv *http.Server) Opt {
+	return func(p *Profiler) {
+		p.server = srv
+	}
+}
+
+// WithBaseContext sets the base context of the requests to the pprof endpoint, see http.Server.BaseContext.
+// It takes precedence over the BaseContext of WithHTTPServer.
+// By default the context of StartContext is used, the requests are canceled when the signal handler is stopped.
+func WithBaseContext(f func(net.Listener) context.Context) Opt {
+	return func(p *Profiler) {
+		p.baseContext = f
+	}
+}
+
+// WithServeContext cancels the context of the requests to the pprof endpoint as soon as the window closes,
+// e.g. to stop long running extra handlers. The requests are still given the drain timeout to return.
+// The option has no effect if a base context is set with WithBaseContext or WithHTTPServer.
+func WithServeContext(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.serveContext = enabled
+	}
+}
+
+// WithConnState registers a callback for connection state changes of the pprof endpoint,
+// see http.Server.ConnState. The callback is called after the ConnState of WithHTTPServer.
+func WithConnState(f func(net.Conn, http.ConnState)) Opt {
+	return func(p *Profiler) {
+		p.connStates = append(p.connStates, f)
+	}
+}
+
+// WithShutdownCallback sets a callback which is executed exactly once per activation after the PostShutdown hooks,
+// on every path the pprof endpoint terminates: shutdown after the timeout, the drain signal or Stop (err is nil),
+// a failed start of the endpoint (e.g. a BindError), a panic or a Stop before the endpoint started (context.Canceled).
+func WithShutdownCallback(f func(err error)) Opt {
+	return func(p *Profiler) {
+		p.shutdownCallback = f
+	}
+}
+
+// WithShutdownOnPanic recovers a panic during an activation of the pprof endpoint (e.g. in a PreStart hook),
+// the endpoint is shutdown and the profiler waits for the next activation. Without the option the panic
+// is propagated. In both cases the PostShutdown hooks are executed.
+func WithShutdownOnPanic(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.shutdownOnPanic = enabled
+	}
+}
+
+// WithStartConflictHandler sets a function which is called if Start is called
+// while the signal handler is already running, additionally to the emitted event
+func WithStartConflictHandler(f func()) Opt {
+	return func(p *Profiler) {
+		p.startConflictHandler = f
+	}
+}
+
 // WithHooks registers the Profiler hooks
 func WithHooks(hooks ...Hooker) Opt {
 	return func(p *Profiler) {
-		p.hooks = append(p.hooks, hooks...)
+		for _, h := range hooks {
+			p.hooks = append(p.hooks, hooker{h})
+		}
 	}
 }
 
 // New returns a new profiler
 // Defaults:
-// - Signal : syscall.SIGHUP
+// - Signal : syscall.SIGHUP (nil on windows, activated with Trigger only)
 // - Address: ":6666"
 // - Timeout: 10m
 func New(opts ...Opt) *Profiler {
 	p := &Profiler{
-		signal:  syscall.SIGHUP,
+		signal:  defaultSignal,
 		address: ":6666",
 		timeout: 10 * time.Minute,
-		stop:    make(chan struct{}),
-		done:    make(chan struct{}),
-		once:    new(sync.Once),
+		clock:   realClock{},
+
+		signalBuffer: 1,
+		eventHandler: logEventHandler,
+		metrics:      &metrics{},
+
+		started: make(chan struct{}),
+		window:  make(chan struct{}),
+		closed:  make(chan struct{}),
+		trigger: make(chan activationRequest),
+		probeID: newRequestID(),
+
+		requestLimit: make(chan struct{}, 1),
 	}
 
 	for _, opt := range opts {
 		opt(p)
 	}
 
+	if p.strict {
+		err := p.checkConfig()
+		if err == nil {
+			err = checkTerminationSignal(p.signal)
+		}
+
+		if err != nil {
+			panic(fmt.Errorf("profiler: invalid configuration: %w", err))
+		}
+	}
+
+	if p.signalErr != nil {
+		p.evt(ErrorEvent, CodeInvalidOption, MsgInvalidOption, "error", p.signalErr)
+	}
+
+	if err := checkSignal(p.signal); err != nil {
+		p.evt(ErrorEvent, CodeSignalUnsupported, MsgSignalUnsupported, "error", err)
+	}
+
+	if err := p.checkAutoCapture(); err != nil {
+		p.ignoreOption(err)
+		p.autoCapture = nil
+	}
+
+	if err := p.checkAutoTrigger(); err != nil {
+		p.ignoreOption(err)
+		p.autoTriggerCond = nil
+	}
+
+	if err := p.checkContinuous(); err != nil {
+		p.ignoreOption(err)
+		p.continuous = ContinuousConfig{}
+	}
+
+	if err := p.checkHeapSnapshots(); err != nil {
+		p.ignoreOption(err)
+		p.snapshots = nil
+	}
+
+	if err := checkTerminationSignal(p.signal); err != nil {
+		p.evt(WarnEvent, CodeTerminationSignal, MsgTerminationSignal, "error", err)
+	}
+
+	trustedProxies, err := parseCIDRs(p.trustedProxyCIDRs)
+	if err != nil {
+		p.ignoreOption(err)
+		trustedProxies = nil
+	}
+
+	p.trustedProxies = trustedProxies
+	p.checkRoutes()
+	p.publishExpvars()
+	p.publishConfig()
+
+	if p.logConfigOnInit {
+		p.logConfig()
+	}
+
 	return p
 }
 
+// ignoreOption reports an invalid option which New ignores, checkConfig returns the error of the first one
+func (p *Profiler) ignoreOption(err error) {
+	p.evt(ErrorEvent, CodeInvalidOption, MsgInvalidOption, "error", err)
+
+	if p.ignoredErr == nil {
+		p.ignoredErr = err
+	}
+}
+
+// NewWithError returns a new profiler like New, but validates the configuration
+func NewWithError(opts ...Opt) (*Profiler, error) {
+	p := New(opts...)
+
+	if err := p.checkConfig(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// checkConfig validates the configuration without side effects
+func (p *Profiler) checkConfig() error {
+	if p.signalErr != nil {
+		return p.signalErr
+	}
+
+	if p.ignoredErr != nil {
+		return p.ignoredErr
+	}
+
+	if err := checkSignal(p.signal); err != nil {
+		return err
+	}
+
+	if err := p.checkAutoCapture(); err != nil {
+		return err
+	}
+
+	if err := p.checkAutoTrigger(); err != nil {
+		return err
+	}
+
+	if err := p.checkContinuous(); err != nil {
+		return err
+	}
+
+	if err := p.checkHeapSnapshots(); err != nil {
+		return err
+	}
+
+	switch p.listenNetwork() {
+	case "tcp", "tcp4", "tcp6":
+		for _, addr := range p.listenAddresses() {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return fmt.Errorf("%w %q: %v", ErrInvalidAddress, addr, err)
+			}
+		}
+	case "unix":
+		if p.portLow != 0 || p.portHigh != 0 {
+			return fmt.Errorf("%w: port range with unix network", ErrInvalidAddress)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported network %q", ErrInvalidAddress, p.network)
+	}
+
+	if _, err := parseCIDRs(p.trustedProxyCIDRs); err != nil {
+		return err
+	}
+
+	for i := range p.extraHandlers {
+		if err := p.extraConflict(i); err != nil {
+			return err
+		}
+	}
+
+	if p.portLow != 0 || p.portHigh != 0 {
+		if p.portLow <= 0 || p.portHigh > 65535 || p.portLow > p.portHigh {
+			return fmt.Errorf("%w: port range %d-%d", ErrInvalidAddress, p.portLow, p.portHigh)
+		}
+	}
+
+	return nil
+}
+
 // Address returns the listen address for the pprof endpoint
 func (p *Profiler) Address() string {
+	p.Lock()
+	defer p.Unlock()
+
 	return p.address
 }
 
 // Start the pprof signal handler
 func (p *Profiler) Start() {
-	go func() {
-		p.once.Do(p.handler)
-	}()
+	_ = p.StartWithError()
+}
+
+// StartWithError starts the pprof signal handler like Start,
+// but returns ErrAlreadyRunning if the signal handler is already running
+func (p *Profiler) StartWithError() error {
+	return p.StartContext(context.Background())
+}
+
+// StartContext starts the pprof signal handler like StartWithError.
+// The signal handler is stopped when the context is done.
+// If the context is already done, the signal handler is not started and the context error is returned.
+// A context which can not be canceled (e.g. context.Background) is reported by a DebugEvent,
+// the signal handler is then only stopped by Stop (or WithHandlerTTL and WithHandlerDeadline).
+func (p *Profiler) StartContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		p.evt(WarnEvent, CodeStartCanceled, MsgStartCanceled, "error", err)
+		return err
+	}
+
+	p.Lock()
+
+	if p.running {
+		p.Unlock()
+		p.startConflict()
+
+		return ErrAlreadyRunning
+	}
+
+	// without cancellation the signal handler runs until Stop is called
+	stopOnly := ctx.Done() == nil && p.handlerDeadline.IsZero() && p.handlerTTL <= 0
+	ctx, cancel := p.handlerContext(ctx)
+
+	p.running = true
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go p.handler(ctx, cancel, p.done, stopOnly)
+
+	p.Unlock()
+
+	return nil
+}
+
+// handlerContext returns the context of the signal handler, limited by the handler deadline or TTL
+func (p *Profiler) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	switch {
+	case !p.handlerDeadline.IsZero():
+		return context.WithDeadline(ctx, p.handlerDeadline)
+	case p.handlerTTL > 0:
+		return context.WithTimeout(ctx, p.handlerTTL)
+	default:
+		return context.WithCancel(ctx)
+	}
 }
 
-// Stop the pprof signal handler
+// startConflict reports a Start while the signal handler is already running
+func (p *Profiler) startConflict() {
+	p.evt(InfoEvent, CodeStartIgnored, MsgStartIgnored)
+
+	if p.startConflictHandler != nil {
+		p.startConflictHandler()
+	}
+}
+
+// Started returns a channel which is closed when the signal handler is installed
+func (p *Profiler) Started() <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.started
+}
+
+// WindowOpen returns a channel which is closed when the pprof endpoint of the current
+// (or next) activation is serving. The channel is not closed if the endpoint fails to start.
+func (p *Profiler) WindowOpen() <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.window
+}
+
+// Stop the pprof signal handler and wait until it is stopped
 func (p *Profiler) Stop() {
-	p.stop <- struct{}{}
-	<-p.done
-	p.reset()
+	p.Lock()
+
+	if !p.running {
+		p.Unlock()
+		return
+	}
+
+	cancel, done := p.cancel, p.done
+	p.Unlock()
+
+	cancel()
+	<-done
+}
+
+// newServer returns the http server for the pprof endpoint
+//
+// A new server is created for every activation, because a server can not be reused after shutdown.
+// The settings are copied from the server configured with WithHTTPServer.
+func (p *Profiler) newServer(ctx context.Context) *http.Server {
+	srv := &http.Server{}
+
+	if t := p.server; t != nil {
+		srv.ReadTimeout = t.ReadTimeout
+		srv.ReadHeaderTimeout = t.ReadHeaderTimeout
+		srv.WriteTimeout = t.WriteTimeout
+		srv.IdleTimeout = t.IdleTimeout
+		srv.MaxHeaderBytes = t.MaxHeaderBytes
+		srv.TLSNextProto = t.TLSNextProto
+		srv.ConnState = t.ConnState
+		srv.ErrorLog = t.ErrorLog
+		srv.BaseContext = t.BaseContext
+		srv.ConnContext = t.ConnContext
+
+		if t.TLSConfig != nil {
+			srv.TLSConfig = t.TLSConfig.Clone()
+		}
+	}
+
+	srv.TLSConfig = p.reloadableTLS(srv.TLSConfig)
+
+	srv.Addr = p.address
+	srv.Handler = p.mux()
+
+	if srv.ErrorLog == nil {
+		srv.ErrorLog = log.New(eventWriter{p}, "", 0)
+	}
+
+	if p.baseContext != nil {
+		srv.BaseContext = p.baseContext
+	}
+
+	if srv.BaseContext == nil {
+		if p.serveContext {
+			// the requests are canceled when the shutdown of the window starts
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithCancel(ctx)
+			srv.RegisterOnShutdown(cancel)
+		}
+
+		// the requests are canceled when the signal handler is stopped
+		srv.BaseContext = func(net.Listener) context.Context {
+			return ctx
+		}
+	}
+
+	if p.maxHeaderBytes != 0 {
+		srv.MaxHeaderBytes = p.maxHeaderBytes
+	}
+
+	connStates := append([]func(net.Conn, http.ConnState){srv.ConnState, p.trackConn}, p.connStates...)
+	srv.ConnState = chainConnState(connStates)
+
+	return srv
+}
+
+// chainConnState returns a ConnState callback calling all non nil callbacks in order
+func chainConnState(callbacks []func(net.Conn, http.ConnState)) func(net.Conn, http.ConnState) {
+	return func(c net.Conn, s http.ConnState) {
+		for _, f := range callbacks {
+			if f != nil {
+				f(c, s)
+			}
+		}
+	}
 }
 
 func (p *Profiler) reset() {
 	p.Lock()
-	p.once = new(sync.Once) // reset sync.Once for a subsequent call to Start
+	p.running = false // allow a subsequent call to Start
+	p.started = make(chan struct{})
 	p.Unlock()
 }
 
-func (p *Profiler) handler() {
-	log.Printf("start profiler handler - pprof endpoint will be started on signal: %v", p.signal)
+// setStarted marks the signal handler as installed
+func (p *Profiler) setStarted() {
+	p.Lock()
+	defer p.Unlock()
 
-	defer log.Println("profiler handler stopped")
+	select {
+	case <-p.started:
+	default:
+		close(p.started)
+	}
+}
 
-	sig := make(chan os.Signal, 1)
+// openWindow marks the pprof endpoint as serving
+func (p *Profiler) openWindow() {
+	p.Lock()
+	defer p.Unlock()
 
-	for {
-		// signal handling
+	close(p.window)
+	p.windowDeadline = p.clock.Now().Add(p.windowTimeout)
+}
+
+// closeWindow prepares the window channel for the next activation
+func (p *Profiler) closeWindow() {
+	p.Lock()
+	defer p.Unlock()
+
+	close(p.closed)
+	atomic.StoreInt32(&p.closing, 0)
+
+	p.window = make(chan struct{})
+	p.closed = make(chan struct{})
+	p.windowDeadline = time.Time{}
+}
+
+func (p *Profiler) handler(ctx context.Context, cancel context.CancelFunc, done chan struct{}, stopOnly bool) {
+	p.evt(InfoEvent, CodeHandlerStarted, MsgHandlerStarted, "signal", p.signal)
+
+	if stopOnly {
+		p.evt(DebugEvent, CodeNotCancelable, MsgNotCancelable)
+	}
+
+	var wg sync.WaitGroup
+
+	defer func() {
+		cancel()
+		wg.Wait()
+		p.evt(InfoEvent, CodeHandlerStopped, MsgHandlerStopped)
+		p.reset()
+		close(done)
+	}()
+
+	sig := p.newSignalChannel()
+
+	if p.autoTriggerCond != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			p.autoTrigger(ctx)
+		}()
+	}
+
+	if p.continuous.Sink != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			p.continuousProfiling(ctx)
+		}()
+	}
+
+	if p.snapshots != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			p.heapSnapshots(ctx)
+		}()
+	}
+
+	// the signals are received during the whole lifetime of the handler, signals received
+	// while the pprof endpoint is open are ignored
+	if p.signal != nil {
 		signal.Notify(sig, p.signal)
-		select {
-		case <-sig:
-			disableSignals(sig)
-		case <-p.stop:
-			disableSignals(sig)
-			p.done <- struct{}{}
+	}
+
+	defer disableSignals(sig)
 
+	var drain chan os.Signal
+
+	if p.drainSignal != nil {
+		drain = p.newSignalChannel()
+		signal.Notify(drain, p.drainSignal)
+
+		defer disableSignals(drain)
+	}
+
+	p.setStarted()
+
+	for {
+		var req activationRequest
+
+		select {
+		case s := <-sig:
+			p.evt(InfoEvent, CodeSignalReceived, MsgSignalReceived, "signal", s, "result", signalActivated)
+		case s := <-drain: // no open window
+			p.evt(InfoEvent, CodeSignalReceived, MsgSignalReceived, "signal", s, "result", signalIgnored)
+			continue
+		case req = <-p.trigger:
+		case <-ctx.Done():
 			return
 		}
+
+		if p.startDelay > 0 && !p.delayStart(ctx, drain) {
+			req.fail()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			continue
+		}
+
+		if !openWindows.acquire() {
+			p.evt(WarnEvent, CodeWindowRejected, MsgWindowRejected, "max", openWindows.limit())
+			req.fail()
+
+			continue
+		}
+
+		p.Lock()
+		p.activationReason = req.reason
+
+		if req.ready != nil {
+			go notifyReady(req.ready, p.window, p.closed)
+		}
+
+		p.Unlock()
+
+		// discard the signals buffered before the activation and a request limit reached by the previous one
+		p.ignoreSignals(sig)
+
+		select {
+		case <-p.requestLimit:
+		default:
+		}
+
+		timeout := p.activationTimeout()
+
 		// start the pprof endpoint
 		shutdown := make(chan struct{})
-		srv := &http.Server{
-			Addr:    p.address,
-			Handler: pprofmux,
+		srv := p.newServer(ctx)
+
+		stopCapture := p.startAutoCapture(ctx)
+
+		go p.runWindow(ctx, srv, shutdown)
+
+		stopped := p.waitWindow(ctx, sig, drain, timeout, srv, shutdown)
+
+		stopCapture()
+		openWindows.release()
+
+		if stopped || p.signalOnce {
+			return
 		}
 
-		go func() {
-			log.Printf("start pprof endpoint on %q\n", p.address)
-			// execute the PreStart hooks
-			for _, h := range p.hooks {
-				h.PreStart()
-			}
+		p.ignoreSignals(sig)
+	}
+}
+
+// activationTimeout returns the timeout of the next window, see WithTimeoutFunc and WithTimeoutJitter
+func (p *Profiler) activationTimeout() time.Duration {
+	timeout := p.timeout
+
+	if p.timeoutFunc != nil {
+		if d := p.timeoutFunc(); d > 0 {
+			timeout = d
+		}
+	}
+
+	timeout = jitter(timeout, p.timeoutJitter)
+
+	p.Lock()
+	p.windowTimeout = timeout
+	p.Unlock()
+
+	return timeout
+}
+
+// delayStart waits for the start delay and reports whether the pprof endpoint is started,
+// the activation is canceled by the drain signal or if the context is done
+func (p *Profiler) delayStart(ctx context.Context, drain chan os.Signal) bool {
+	timer := p.clock.NewTimer(p.startDelay)
+
+	select {
+	case <-timer.C():
+		return true
+	case s := <-drain:
+		timer.Stop()
+		p.evt(InfoEvent, CodeSignalReceived, MsgSignalReceived, "signal", s, "result", signalCanceled)
+
+		return false
+	case <-ctx.Done():
+		timer.Stop()
+		return false
+	}
+}
+
+// waitWindow waits until the pprof endpoint is shutdown after the timeout or the drain signal,
+// the endpoint failed or the context is done. It reports whether the context is done.
+func (p *Profiler) waitWindow(ctx context.Context, sig, drain chan os.Signal, timeout time.Duration,
+	srv *http.Server, shutdown chan struct{}) bool {
+	start := p.startWindowStats()
+	timer := p.clock.NewTimer(timeout)
+
+	for {
+		select {
+		case s := <-sig: // already open
+			p.evt(InfoEvent, CodeSignalReceived, MsgSignalReceived, "signal", s, "result", signalIgnored)
+		case s := <-drain: // close requested
+			p.evt(InfoEvent, CodeSignalReceived, MsgSignalReceived, "signal", s, "result", signalDrained)
 
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Println("failed to start pprof endpoint:", err)
-			} else {
-				log.Println("pprof endpoint stopped")
+			if !timer.Stop() {
+				<-timer.C()
 			}
-			// execute the PostShutdown hooks ... even after a failed startup
-			for _, h := range p.hooks {
-				h.PostShutdown()
+
+			p.shutdownEndpoint(srv, p.drainTimeout())
+			<-shutdown
+			p.reportWindowStats(start, CloseDrained)
+
+			return false
+		case <-timer.C(): // timer expired
+			p.shutdownEndpoint(srv, p.drainTimeout())
+			<-shutdown
+			p.reportWindowStats(start, CloseTimeout)
+
+			return false
+		case <-p.requestLimit: // maximum number of requests served
+			if !timer.Stop() {
+				<-timer.C()
 			}
 
-			close(shutdown)
-		}()
-		//
-		timer := time.NewTimer(p.timeout)
-		select {
-		case <-timer.C: // timer expired
-			shutdownEndpoint(srv, p.timeout)
+			p.shutdownEndpoint(srv, p.drainTimeout())
 			<-shutdown
+			p.reportWindowStats(start, CloseLimit)
+
+			return false
 		case <-shutdown: // start of endpoint failed
 			if !timer.Stop() {
-				<-timer.C
+				<-timer.C()
 			}
-		case <-p.stop: // stop requested
+
+			p.reportWindowStats(start, CloseFailed)
+
+			return false
+		case <-ctx.Done(): // stop requested
 			if !timer.Stop() {
-				<-timer.C
+				<-timer.C()
 			}
 
-			shutdownEndpoint(srv, p.timeout)
+			p.shutdownEndpoint(srv, p.drainTimeout())
 			<-shutdown
-			p.done <- struct{}{}
+			p.reportWindowStats(start, CloseStopped)
 
-			return
+			return true
 		}
 	}
 }
 
-// disableSignals stop receiving of signals and drain the signal channel
-func disableSignals(c chan os.Signal) {
-	signal.Stop(c)
-	// drain signal channel
-	select {
-	case <-c:
-	default:
+// runWindow serves the pprof endpoint until it is shutdown and executes the hooks
+//
+// The PostShutdown hooks and the shutdown callback are executed even if the window panics. The panic is recovered
+// if WithShutdownOnPanic is set, otherwise it is propagated after the hooks ran.
+func (p *Profiler) runWindow(ctx context.Context, srv *http.Server, shutdown chan struct{}) {
+	var err error
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			p.evt(ErrorEvent, CodeWindowPanic, MsgWindowPanic, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("pprof endpoint panicked: %v", r)
+			// the panic may occur after the listeners are bound, e.g. in the OnListen callback
+			_ = srv.Close()
+		}
+
+		p.runPostShutdownHooks(ctx)
+
+		if p.shutdownCallback != nil {
+			p.shutdownCallback(err)
+		}
+
+		p.closeWindow()
+		close(shutdown)
+
+		if r != nil && !p.shutdownOnPanic {
+			panic(r)
+		}
+	}()
+
+	p.evt(InfoEvent, CodeWindowOpening, MsgEndpointStarting, p.withReason("address", p.address)...)
+	// execute the PreStart hooks
+	hctx := p.hookContext(ctx)
+	for _, h := range p.hooks {
+		h.PreStart(hctx)
+	}
+
+	// the profiler was stopped while the PreStart hooks were executed
+	if ctx.Err() != nil {
+		err = ctx.Err()
+		p.evt(InfoEvent, CodeWindowClosed, MsgEndpointStopped, "error", err)
+
+		return
+	}
+
+	if err = p.serve(ctx, srv); err != nil && err != http.ErrServerClosed {
+		code := CodeEndpointFailed
+		if errors.Is(err, ErrBindFailed) {
+			code = CodeBindFailed
+		}
+
+		p.evt(ErrorEvent, code, MsgEndpointFailed, "error", err)
+
+		return
 	}
+
+	err = nil
+
+	p.evt(InfoEvent, CodeWindowClosed, MsgEndpointStopped)
+}
+
+// RunPostShutdownHooks executes the PostShutdown hooks
+//
+// The hooks are executed after every activation of the pprof endpoint, even after a failed startup.
+// RunPostShutdownHooks allows to execute them from a panic handler of the application as well.
+func (p *Profiler) RunPostShutdownHooks() {
+	p.runPostShutdownHooks(context.Background())
+}
+
+// runPostShutdownHooks executes the PostShutdown hooks with the context
+func (p *Profiler) runPostShutdownHooks(ctx context.Context) {
+	hctx := p.hookContext(ctx)
+	for _, h := range p.hooks {
+		h.PostShutdown(hctx)
+	}
+}
+
+// drainTimeout returns the duration to wait for in-flight requests on shutdown
+func (p *Profiler) drainTimeout() time.Duration {
+	if p.drain > 0 {
+		return p.drain
+	}
+
+	return p.timeout
 }
 
 // shutdownEndpoint shutdown the http server graceful
-func shutdownEndpoint(srv *http.Server, timeout time.Duration) {
-	log.Printf("shutdown pprof endpoint on %q\n", srv.Addr)
+func (p *Profiler) shutdownEndpoint(srv *http.Server, timeout time.Duration) {
+	p.runPreShutdownHooks()
+	p.evt(InfoEvent, CodeWindowClosing, MsgEndpointShutdown, "address", srv.Addr)
+	p.announceClosing()
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Println("failed to shutdown pprof endpoint:", err)
+		p.evt(ErrorEvent, CodeShutdownFailed, MsgEndpointShutdownFail, "error", err)
+		// close the remaining connections
+		_ = srv.Close()
 	}
 }
@@ -1,22 +1,77 @@
 package profiler
 
 import (
-	"syscall"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// freeAddress returns a free local address
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+// activation activates the pprof endpoint of a started profiler
+type activation func(t *testing.T, p *Profiler)
+
+// activations are the mechanisms to activate the pprof endpoint
+// nolint: gochecknoglobals
+var activations = map[string]activation{
+	"signal": sendSignal,
+	"trigger": func(t *testing.T, p *Profiler) {
+		require.NoError(t, p.Trigger(context.Background()))
+	},
+}
+
+// openWindow starts the profiler and activates the pprof endpoint with the test signal
+// The returned timer closes the window when fired.
+func openWindow(t *testing.T, p *Profiler, c *fakeClock) *fakeTimer {
+	return openWindowWith(t, p, c, activations["signal"])
+}
+
+// openWindowWith starts the profiler and activates the pprof endpoint with the activation
+func openWindowWith(t *testing.T, p *Profiler, c *fakeClock, activate activation) *fakeTimer {
+	p.Start()
+	<-p.Started()
+
+	open := p.WindowOpen()
+	activate(t, p)
+
+	timer := <-c.timers
+	<-open
+
+	return timer
+}
+
 func TestDefaultProfiler(t *testing.T) {
 	p := New()
-	assert.Equal(t, syscall.SIGHUP, p.signal)
+	assert.Equal(t, defaultSignal, p.signal)
 	assert.Equal(t, ":6666", p.address)
 	assert.Equal(t, 10*time.Minute, p.timeout)
 }
 
 func TestWithSignal(t *testing.T) {
-	signal := syscall.SIGUSR2
+	signal := testSignal
 	p := New(WithSignal(signal))
 	assert.Equal(t, signal, p.signal)
 }
@@ -32,3 +87,551 @@ func TestWithTimeout(t *testing.T) {
 	p := New(WithTimeout(timeout))
 	assert.Equal(t, timeout, p.timeout)
 }
+
+func TestStarted(t *testing.T) {
+	p := New(WithSignal(testSignal), WithAddress("localhost:0"))
+
+	started := p.Started()
+	select {
+	case <-started:
+		t.Fatal("started before Start")
+	default:
+	}
+
+	p.Start()
+	<-started
+	p.Stop()
+
+	select {
+	case <-p.Started():
+		t.Fatal("started after Stop")
+	default:
+	}
+}
+
+func TestWithDrainTimeout(t *testing.T) {
+	drain := 30 * time.Second
+	p := New(WithDrainTimeout(drain))
+	assert.Equal(t, drain, p.drain)
+	assert.Equal(t, drain, p.drainTimeout())
+	assert.Equal(t, p.timeout, New().drainTimeout())
+}
+
+func TestDrainTimeout(t *testing.T) {
+	tt := []struct {
+		drain   time.Duration
+		success bool
+	}{
+		{5 * time.Second, true},
+		{100 * time.Millisecond, false},
+	}
+
+	for _, tc := range tt {
+		c := newFakeClock()
+		address := freeAddress(t)
+		p := New(
+			WithSignal(testSignal),
+			WithAddress(address),
+			WithClock(c),
+			WithDrainTimeout(tc.drain),
+		)
+
+		timer := openWindow(t, p, c)
+
+		errC := make(chan error, 1)
+
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/profile?seconds=1", address))
+			if err == nil {
+				_, err = ioutil.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+			}
+			errC <- err
+		}()
+
+		time.Sleep(200 * time.Millisecond) // wait until the profile is running
+		timer.fire()
+
+		err := <-errC
+		assert.Equal(t, tc.success, err == nil, tc.drain)
+
+		p.Stop()
+	}
+}
+
+func TestWithMaxHeaderBytes(t *testing.T) {
+	p := New(WithMaxHeaderBytes(4096))
+	assert.Equal(t, 4096, p.maxHeaderBytes)
+	assert.Equal(t, 4096, p.newServer(context.Background()).MaxHeaderBytes)
+}
+
+func TestStartContextCanceled(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithEventHandler(r.handle),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, p.StartContext(ctx))
+	assert.Equal(t, 1, r.count(MsgStartCanceled))
+	assert.Equal(t, 0, r.count(MsgHandlerStarted))
+
+	// the profiler is not running
+	require.NoError(t, p.StartWithError())
+	<-p.Started()
+	p.Stop()
+}
+
+func TestStartContext(t *testing.T) {
+	p := New(WithSignal(testSignal), WithAddress(freeAddress(t)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, p.StartContext(ctx))
+	<-p.Started()
+
+	p.Lock()
+	done := p.done
+	p.Unlock()
+
+	cancel()
+	<-done
+
+	// Stop on a stopped profiler returns immediately
+	p.Stop()
+
+	require.NoError(t, p.StartWithError())
+	<-p.Started()
+	p.Stop()
+}
+
+// testCertificate returns a self-signed certificate for localhost
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
+
+func TestWithHTTPServer(t *testing.T) {
+	tmpl := &http.Server{
+		Addr:         ":1234",
+		ReadTimeout:  time.Second,
+		WriteTimeout: 2 * time.Second,
+		IdleTimeout:  3 * time.Second,
+		TLSConfig:    &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+
+	p := New(WithHTTPServer(tmpl), WithAddress(":8080"))
+
+	srv := p.newServer(context.Background())
+	assert.Equal(t, ":8080", srv.Addr)
+	assert.NotNil(t, srv.Handler)
+	assert.Equal(t, tmpl.ReadTimeout, srv.ReadTimeout)
+	assert.Equal(t, tmpl.WriteTimeout, srv.WriteTimeout)
+	assert.Equal(t, tmpl.IdleTimeout, srv.IdleTimeout)
+	assert.Equal(t, uint16(tls.VersionTLS12), srv.TLSConfig.MinVersion)
+	assert.False(t, srv.TLSConfig == tmpl.TLSConfig, "TLSConfig must be cloned")
+}
+
+func TestTLS(t *testing.T) {
+	cert := testCertificate(t)
+	c := newFakeClock()
+	address := freeAddress(t)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithReadinessProbe(true),
+		WithHTTPServer(&http.Server{
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+
+	pool := x509.NewCertPool()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	pool.AddCert(leaf)
+
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/debug/pprof/", address))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithConnState(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	states := make(chan http.ConnState, 10)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithHTTPServer(&http.Server{
+			ConnState: func(_ net.Conn, s http.ConnState) {
+				if s == http.StateNew {
+					states <- s
+				}
+			},
+		}),
+		WithConnState(func(_ net.Conn, s http.ConnState) {
+			states <- s
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+
+	// the callback of the template is called first
+	assert.Equal(t, http.StateNew, <-states)
+	assert.Equal(t, http.StateNew, <-states)
+	assert.Equal(t, http.StateActive, <-states)
+}
+
+type panicHook chan struct{}
+
+func (panicHook) PreStart() {
+	panic("prestart failed")
+}
+
+func (h panicHook) PostShutdown() {
+	h <- struct{}{}
+}
+
+func TestWithShutdownOnPanic(t *testing.T) {
+	c := newFakeClock()
+	hook := make(panicHook, 1)
+	events := &eventRecorder{}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithHooks(hook),
+		WithShutdownOnPanic(true),
+		WithEventHandler(events.handle),
+	)
+	assert.True(t, p.shutdownOnPanic)
+
+	p.Start()
+	<-p.Started()
+	sendSignal(t, p)
+
+	// the PostShutdown hooks are executed despite the panic
+	<-hook
+	p.Stop()
+
+	assert.Equal(t, 1, events.count(MsgWindowPanic))
+}
+
+func TestShutdownOnPanicAfterBind(t *testing.T) {
+	address := freeAddress(t)
+	hook := make(shutdownHook, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithHooks(hook),
+		WithShutdownOnPanic(true),
+		WithEventHandler(func(EventType, string, ...interface{}) {}),
+		WithOnListen(func([]string) {
+			panic("on listen")
+		}),
+	)
+
+	p.Start()
+	<-p.Started()
+	sendSignal(t, p)
+	<-hook
+
+	// the listener is released after the panic
+	l, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+	_ = l.Close()
+
+	p.Stop()
+}
+
+func TestRunPostShutdownHooks(t *testing.T) {
+	hook := make(shutdownHook, 1)
+	p := New(WithHooks(hook))
+
+	p.RunPostShutdownHooks()
+	<-hook
+}
+
+func TestWithTimeoutFunc(t *testing.T) {
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithTimeout(time.Minute),
+		WithTimeoutFunc(func() time.Duration {
+			return time.Hour
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+	assert.Equal(t, time.Hour, p.windowRemaining())
+
+	timer.fire()
+	p.Stop()
+
+	// the static timeout is used if the function returns no timeout
+	p = New(WithTimeout(time.Minute), WithTimeoutFunc(func() time.Duration { return 0 }))
+	assert.Equal(t, time.Minute, p.activationTimeout())
+}
+
+type baseContextKey struct{}
+
+func TestWithBaseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the requests are canceled with the context of the signal handler by default
+	srv := New().newServer(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, srv.BaseContext(nil).Err())
+
+	base := context.WithValue(context.Background(), baseContextKey{}, "value")
+	c := newFakeClock()
+	address := freeAddress(t)
+	values := make(chan interface{}, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithBaseContext(func(net.Listener) context.Context {
+			return base
+		}),
+		WithExtraHandler("/debug/app", http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			values <- r.Context().Value(baseContextKey{})
+		})),
+	)
+
+	timer := openWindow(t, p, c)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/app", address))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "value", <-values)
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithServeContext(t *testing.T) {
+	c := newFakeClock()
+	address := freeAddress(t)
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+		WithServeContext(true),
+		WithExtraHandler("/debug/slow", http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+			canceled <- r.Context().Err()
+		})),
+	)
+
+	timer := openWindow(t, p, c)
+
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/debug/slow", address))
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	<-started
+	timer.fire()
+
+	// the handler returns within the drain timeout of the window
+	select {
+	case err := <-canceled:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("request context not canceled at window close")
+	}
+
+	p.Stop()
+}
+
+func TestStartContextNotCancelable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for name, tc := range map[string]struct {
+		start func(p *Profiler) error
+		opts  []Opt
+		count int
+	}{
+		"background": {start: func(p *Profiler) error { return p.StartContext(context.Background()) }, count: 1},
+		"start":      {start: func(p *Profiler) error { return p.StartWithError() }, count: 1},
+		"cancelable": {start: func(p *Profiler) error { return p.StartContext(ctx) }},
+		"ttl": {
+			start: func(p *Profiler) error { return p.StartContext(context.Background()) },
+			opts:  []Opt{WithHandlerTTL(time.Hour)},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			r := &eventRecorder{}
+			p := New(append([]Opt{WithSignal(testSignal), WithEventHandler(r.handle)}, tc.opts...)...)
+
+			require.NoError(t, tc.start(p))
+			<-p.Started()
+			p.Stop()
+
+			assert.Equal(t, tc.count, r.count(MsgNotCancelable))
+		})
+	}
+}
+
+func TestWithHandlerTTL(t *testing.T) {
+	for name, opt := range map[string]func() Opt{
+		"ttl":      func() Opt { return WithHandlerTTL(50 * time.Millisecond) },
+		"deadline": func() Opt { return WithHandlerDeadline(time.Now().Add(50 * time.Millisecond)) },
+	} {
+		opt := opt
+
+		t.Run(name, func(t *testing.T) {
+			p := New(WithSignal(testSignal), WithAddress(freeAddress(t)), opt())
+
+			p.Start()
+			<-p.Started()
+
+			p.Lock()
+			done := p.done
+			p.Unlock()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("signal handler not stopped")
+			}
+
+			assert.True(t, errors.Is(p.Trigger(context.Background()), ErrNotRunning))
+		})
+	}
+}
+
+func TestWithShutdownCallback(t *testing.T) {
+	// newProfiler returns a started profiler reporting the shutdown callback to the channel
+	newProfiler := func(t *testing.T, errs chan error, opts ...Opt) (*Profiler, *fakeClock) {
+		c := newFakeClock()
+		p := New(append([]Opt{
+			WithSignal(testSignal),
+			WithAddress(freeAddress(t)),
+			WithClock(c),
+			WithShutdownCallback(func(err error) {
+				errs <- err
+			}),
+		}, opts...)...)
+
+		return p, c
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		errs := make(chan error, 2)
+		p, c := newProfiler(t, errs)
+
+		openWindow(t, p, c).fire()
+		assert.NoError(t, <-errs)
+		p.Stop()
+		assert.Empty(t, errs)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		errs := make(chan error, 2)
+		p, c := newProfiler(t, errs)
+
+		openWindow(t, p, c)
+		p.Stop()
+		assert.NoError(t, <-errs)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("bind failure", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+
+		defer l.Close()
+
+		errs := make(chan error, 2)
+		p, _ := newProfiler(t, errs, WithAddress(l.Addr().String()))
+
+		p.Start()
+		<-p.Started()
+		assert.NoError(t, p.Trigger(context.Background()))
+		assert.True(t, errors.Is(<-errs, ErrBindFailed))
+		p.Stop()
+		assert.Empty(t, errs)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		errs := make(chan error, 2)
+		p, _ := newProfiler(t, errs, WithHooks(make(panicHook, 1)), WithShutdownOnPanic(true))
+
+		p.Start()
+		<-p.Started()
+		assert.NoError(t, p.Trigger(context.Background()))
+		assert.Contains(t, fmt.Sprint(<-errs), "prestart failed")
+		p.Stop()
+		assert.Empty(t, errs)
+	})
+
+	t.Run("stop before start", func(t *testing.T) {
+		hook := slowHook{started: make(chan struct{}), aborted: make(chan error, 1)}
+		errs := make(chan error, 2)
+		p, _ := newProfiler(t, errs, WithContextHooks(hook))
+
+		p.Start()
+		<-p.Started()
+		assert.NoError(t, p.Trigger(context.Background()))
+		<-hook.started
+		p.Stop()
+		assert.Equal(t, context.Canceled, <-errs)
+		assert.Empty(t, errs)
+	})
+}
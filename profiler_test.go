@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"os"
 	"sync"
-	"syscall"
 	"testing"
 	"time"
 
@@ -17,10 +16,7 @@ import (
 )
 
 // nolint: gochecknoglobals
-var (
-	signal  = syscall.SIGUSR2
-	timeout = 3 * time.Second
-)
+var timeout = 3 * time.Second
 
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
@@ -28,9 +24,16 @@ func TestMain(m *testing.M) {
 
 func testProfiler(t *testing.T, p *profiler.Profiler, success bool) {
 	p.Start()
-	time.Sleep(1 * time.Second) // wait until the setup is done
-	assert.NoError(t, syscall.Kill(syscall.Getpid(), signal))
-	time.Sleep(1 * time.Second) // wait until the signal is processed
+	<-p.Started() // wait until the setup is done
+
+	open := p.WindowOpen()
+	sendSignal(t, p)
+
+	if success {
+		<-open // wait until the endpoint is serving
+	} else {
+		time.Sleep(1 * time.Second) // wait until the signal is processed
+	}
 
 	client := http.Client{
 		Timeout: 10 * time.Millisecond,
@@ -173,9 +176,11 @@ func TestWithHooks(t *testing.T) {
 	require.NotNil(t, p)
 
 	p.Start()
-	time.Sleep(1 * time.Second) // wait until the setup is done
-	assert.NoError(t, syscall.Kill(syscall.Getpid(), signal))
-	time.Sleep(1 * time.Second) // wait until the signal is processed
+	<-p.Started() // wait until the setup is done
+
+	open := p.WindowOpen()
+	sendSignal(t, p)
+	<-open // wait until the endpoint is serving
 	assert.True(t, one.HasPreStartupTriggered())
 	assert.True(t, two.HasPreStartupTriggered())
 
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package profiler_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/postfinance/profiler"
+	"github.com/stretchr/testify/assert"
+)
+
+// signal is the activation signal of the tests
+// nolint: gochecknoglobals
+var signal os.Signal = syscall.SIGUSR2
+
+// sendSignal sends the activation signal to the process
+func sendSignal(t *testing.T, _ *profiler.Profiler) {
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+}
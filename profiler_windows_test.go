@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package profiler_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/postfinance/profiler"
+	"github.com/stretchr/testify/assert"
+)
+
+// signal is the activation signal of the tests, a signal can not be sent to a running process on
+// windows, the tests activate the pprof endpoint with Trigger only
+// nolint: gochecknoglobals
+var signal os.Signal
+
+// sendSignal activates the pprof endpoint with Trigger, it replaces the activation signal on windows
+func sendSignal(t *testing.T, p *profiler.Profiler) {
+	assert.NoError(t, p.Trigger(context.Background()))
+}
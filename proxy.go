@@ -0,0 +1,120 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithTrustedProxies takes the client IP of requests from the trusted proxies (CIDRs or IPs)
+// from the header, e.g. "X-Forwarded-For". The rightmost address of the header which is not
+// a trusted proxy is used. The client IP is used by the access log and available with ClientIP,
+// e.g. for an allowlist in the function of WithAuthFunc.
+// By default the client IP is the address of the peer, headers are not trusted. New ignores the option
+// with an ErrorEvent if a CIDR or IP is invalid, NewWithError returns ErrInvalidAddress.
+func WithTrustedProxies(cidrs []string, header string) Opt {
+	return func(p *Profiler) {
+		p.trustedProxyCIDRs = append([]string(nil), cidrs...)
+		p.trustedProxyHeader = header
+	}
+}
+
+type clientIPKey struct{}
+
+// ClientIP returns the IP of the client of a request to the pprof endpoint, see WithTrustedProxies
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+
+	return peerIP(r)
+}
+
+// parseCIDRs parses the CIDRs, a single IP is converted to a CIDR
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nets, fmt.Errorf("%w: trusted proxy %q", ErrInvalidAddress, c)
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nets, fmt.Errorf("%w: trusted proxy %q: %v", ErrInvalidAddress, c, err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// clientIP stores the IP of the client in the context of the request
+func (p *Profiler) clientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := p.resolveClientIP(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+// resolveClientIP returns the rightmost untrusted address if the peer is a trusted proxy
+func (p *Profiler) resolveClientIP(r *http.Request) string {
+	peer := peerIP(r)
+	if !p.trustedProxy(peer) {
+		return peer
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values(p.trustedProxyHeader), ","), ",")
+	client := peer
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		client = hop
+
+		if !p.trustedProxy(hop) {
+			break
+		}
+	}
+
+	return client
+}
+
+// trustedProxy reports whether the IP is a trusted proxy
+func (p *Profiler) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, n := range p.trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peerIP returns the IP of the peer of the connection
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,88 @@
+package profiler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	p := New(WithTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}, "X-Forwarded-For"))
+
+	tt := []struct {
+		remote   string
+		header   string
+		expected string
+	}{
+		{"203.0.113.1:1234", "", "203.0.113.1"},
+		// the header of an untrusted peer is ignored
+		{"203.0.113.1:1234", "198.51.100.1", "203.0.113.1"},
+		{"10.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		// the rightmost untrusted hop is the client
+		{"10.0.0.1:1234", "198.51.100.7, 198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"10.0.0.1:1234", "10.0.0.2", "10.0.0.2"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "garbage", "10.0.0.1"},
+	}
+
+	for _, tc := range tt {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tc.remote
+
+		if tc.header != "" {
+			r.Header.Set("X-Forwarded-For", tc.header)
+		}
+
+		assert.Equal(t, tc.expected, p.resolveClientIP(r), tc)
+	}
+}
+
+func TestTrustedProxiesAuth(t *testing.T) {
+	p := New(
+		WithTrustedProxies([]string{"10.0.0.0/8"}, "X-Forwarded-For"),
+		WithAuthFunc(func(r *http.Request) bool {
+			return ClientIP(r) == "198.51.100.1"
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// without trusted proxies the header is ignored
+	p = New(WithAuthFunc(func(r *http.Request) bool {
+		return ClientIP(r) == "198.51.100.1"
+	}))
+
+	rec = httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	_, err := NewWithError(WithTrustedProxies([]string{"10.0.0.0/33"}, "X-Forwarded-For"))
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+
+	// New ignores the trusted proxies and reports the invalid CIDR
+	var reported []string
+
+	p := New(
+		WithEventHandler(func(_ EventType, msg string, args ...interface{}) {
+			reported = append(reported, msg+" "+fmt.Sprint(args...))
+		}),
+		WithTrustedProxies([]string{"10.0.0.0/8", "10.0.0.0/33"}, "X-Forwarded-For"),
+	)
+	assert.Empty(t, p.trustedProxies)
+	require.Len(t, reported, 1)
+	assert.Contains(t, reported[0], MsgInvalidOption)
+	assert.Contains(t, reported[0], `"10.0.0.0/33"`)
+}
@@ -0,0 +1,61 @@
+package profiler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultRequestIDHeader is the default header of the request ID
+const defaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request ID taken from a request
+const maxRequestIDLength = 128
+
+// WithRequestID takes the ID of a request to the pprof endpoint from the header (default: "X-Request-ID")
+// or generates one if it is missing. The ID is echoed in the header of the response, included in the
+// access log and available with RequestID.
+func WithRequestID(header string) Opt {
+	return func(p *Profiler) {
+		if header == "" {
+			header = defaultRequestIDHeader
+		}
+
+		p.requestIDHeader = header
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of a request to the pprof endpoint, see WithRequestID.
+// It returns an empty string without the option.
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID stores the ID of the request in the context and sets it in the response
+func (p *Profiler) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(p.requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		w.Header().Set(p.requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// newRequestID returns a random ID, the IDs of the processes must not collide in a shared access log
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b[:])
+}
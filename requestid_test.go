@@ -0,0 +1,60 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var logged []interface{}
+
+	ids := make(chan string, 1)
+	p := New(
+		WithRequestID(""),
+		WithAccessLog(true),
+		WithExtraHandler("/debug/app", http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			ids <- RequestID(r)
+		})),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeAccessLog {
+				logged = args
+			}
+		}),
+	)
+	h := p.mux()
+
+	// the ID of the request is echoed
+	req := httptest.NewRequest(http.MethodGet, "/debug/app", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "abc-123", rec.Header().Get("X-Request-ID"))
+	assert.Equal(t, "abc-123", <-ids)
+	require.NotNil(t, logged)
+	assert.Equal(t, []interface{}{"request_id", "abc-123"}, logged[len(logged)-2:])
+
+	// an ID is generated for a request without ID
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/app", nil))
+
+	id := <-ids
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, rec.Header().Get("X-Request-ID"))
+
+	// the header is configurable
+	rec = httptest.NewRecorder()
+	New(WithRequestID("X-Correlation-ID")).mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.NotEmpty(t, rec.Header().Get("X-Correlation-ID"))
+	assert.Empty(t, rec.Header().Get("X-Request-ID"))
+}
+
+func TestNewRequestID(t *testing.T) {
+	id := newRequestID()
+	assert.Len(t, id, 16)
+	assert.NotEqual(t, id, newRequestID())
+}
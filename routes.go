@@ -0,0 +1,293 @@
+package profiler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// nolint: gochecknoglobals
+// indexProfiles are the profiles of the runtime served by the index of net/http/pprof,
+// the heap and allocs profiles are served as delta profiles
+var indexProfiles = []string{"block", "goroutine", "mutex", "threadcreate"}
+
+// nolint: gochecknoglobals
+// reservedPrefixes are the paths served by the Profiler, they can not be used by WithExtraHandler
+var reservedPrefixes = []string{"/debug/pprof", "/debug/vars", bundlePath, snapshotsPath, connsPath, stacksPath, envPath, gcParamsPath}
+
+// extraHandler is a handler registered with WithExtraHandler
+type extraHandler struct {
+	pattern string
+	handler http.Handler
+}
+
+// WithExtraHandler registers an additional handler on the pprof endpoint, e.g. to serve application
+// specific debug information. The pattern is used as for http.ServeMux.
+// A panic of the handler is recovered and answered with 500 Internal Server Error.
+//
+// The paths /debug/pprof, /debug/vars, /debug/bundle, /debug/snapshots, /debug/conns, /debug/stacks,
+// /debug/env and /debug/gcparams (including the paths below them) and the pattern "/" are reserved.
+// A handler with a reserved or already registered pattern is not served, an ErrorEvent is emitted by New
+// and NewWithError returns ErrRouteConflict.
+func WithExtraHandler(pattern string, h http.Handler) Opt {
+	return func(p *Profiler) {
+		p.extraHandlers = append(p.extraHandlers, extraHandler{pattern: pattern, handler: h})
+	}
+}
+
+// WithDisabledRoutes disables routes of the pprof endpoint (e.g. "/debug/pprof/cmdline"),
+// requests to a disabled route are answered with 404 Not Found.
+// A warning is emitted for patterns which are not a route of the pprof endpoint, see RegisteredRoutes.
+func WithDisabledRoutes(patterns ...string) Opt {
+	return func(p *Profiler) {
+		if p.disabledRoutes == nil {
+			p.disabledRoutes = map[string]bool{}
+		}
+
+		for _, pattern := range patterns {
+			p.disabledRoutes[pattern] = true
+		}
+	}
+}
+
+// WithEnabledRoutes enables only the given routes of the pprof endpoint (e.g. "/debug/pprof/heap"),
+// requests to all other routes are answered with 404 Not Found. A route disabled with WithDisabledRoutes
+// stays disabled. Without the option all routes are enabled.
+// A warning is emitted for patterns which are not a route of the pprof endpoint, see RegisteredRoutes.
+func WithEnabledRoutes(patterns ...string) Opt {
+	return func(p *Profiler) {
+		if p.enabledRoutes == nil {
+			p.enabledRoutes = map[string]bool{}
+		}
+
+		for _, pattern := range patterns {
+			p.enabledRoutes[pattern] = true
+		}
+	}
+}
+
+// route is a handler of the pprof endpoint
+type route struct {
+	pattern  string
+	handler  http.Handler
+	disabled bool
+}
+
+// routes returns the handlers of the pprof endpoint for the current options,
+// the handler of a disabled route answers with 404 Not Found
+func (p *Profiler) routes() []route {
+	routes := p.allRoutes()
+
+	for i := range routes {
+		if !p.routeEnabled(routes[i].pattern) {
+			routes[i].handler = http.NotFoundHandler()
+			routes[i].disabled = true
+		}
+	}
+
+	return routes
+}
+
+// routeEnabled reports whether the route is enabled by WithEnabledRoutes and WithDisabledRoutes
+func (p *Profiler) routeEnabled(pattern string) bool {
+	if p.disabledRoutes[pattern] {
+		return false
+	}
+
+	return p.enabledRoutes == nil || p.enabledRoutes[pattern]
+}
+
+// extraConflict returns an error if the pattern of the extra handler is reserved
+// or registered by a previous extra handler
+func (p *Profiler) extraConflict(i int) error {
+	pattern := p.extraHandlers[i].pattern
+	if reservedPattern(pattern) {
+		return fmt.Errorf("%w: %q is reserved", ErrRouteConflict, pattern)
+	}
+
+	for _, e := range p.extraHandlers[:i] {
+		if e.pattern == pattern {
+			return fmt.Errorf("%w: %q is already registered", ErrRouteConflict, pattern)
+		}
+	}
+
+	return nil
+}
+
+// reservedPattern reports whether the pattern overlaps with the routes of the Profiler
+func reservedPattern(pattern string) bool {
+	if pattern == "/" {
+		return true
+	}
+
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	for _, prefix := range reservedPrefixes {
+		if pattern == prefix || strings.HasPrefix(pattern, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkRoutes emits an error for every conflicting extra handler and a warning for every
+// enabled or disabled pattern which is not a route
+func (p *Profiler) checkRoutes() {
+	for i := range p.extraHandlers {
+		if err := p.extraConflict(i); err != nil {
+			p.evt(ErrorEvent, CodeRouteConflict, MsgRouteConflict, "error", err)
+		}
+	}
+
+	known := map[string]bool{}
+	for _, r := range p.allRoutes() {
+		known[r.pattern] = true
+	}
+
+	patterns := make([]string, 0, len(p.disabledRoutes)+len(p.enabledRoutes))
+	for pattern := range p.disabledRoutes {
+		patterns = append(patterns, pattern)
+	}
+
+	for pattern := range p.enabledRoutes {
+		if !p.disabledRoutes[pattern] {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if !known[pattern] {
+			p.evt(WarnEvent, CodeUnknownRoute, MsgUnknownRoute, "pattern", pattern)
+		}
+	}
+}
+
+// allRoutes returns all handlers of the pprof endpoint for the current options
+func (p *Profiler) allRoutes() []route {
+	routes := []route{
+		// handlers registered on http.DefaultServeMux before the package was initialized
+		{pattern: "/", handler: pprofmux},
+		{pattern: "/debug/pprof/", handler: pprofmux},
+		{pattern: "/debug/pprof/symbol", handler: pprofmux},
+	}
+
+	// the profiles served by the index of net/http/pprof
+	for _, name := range indexProfiles {
+		routes = append(routes, route{pattern: "/debug/pprof/" + name, handler: pprofmux})
+	}
+
+	for i, e := range p.extraHandlers {
+		if p.extraConflict(i) == nil {
+			routes = append(routes, route{pattern: e.pattern, handler: p.recoverer(e.handler)})
+		}
+	}
+
+	if p.bundle {
+		routes = append(routes, route{pattern: bundlePath, handler: http.HandlerFunc(p.serveBundle)})
+	}
+
+	if p.snapshots != nil {
+		routes = append(routes,
+			route{pattern: snapshotsPath, handler: http.HandlerFunc(p.serveSnapshots)},
+			route{pattern: snapshotsPath + "/", handler: http.HandlerFunc(p.serveSnapshots)},
+		)
+	}
+
+	if p.connsRoute {
+		routes = append(routes, route{pattern: connsPath, handler: http.HandlerFunc(p.serveConns)})
+	}
+
+	if p.stacksRoute {
+		routes = append(routes, route{pattern: stacksPath, handler: http.HandlerFunc(serveStacks)})
+	}
+
+	if p.envRoute {
+		routes = append(routes, route{pattern: envPath, handler: http.HandlerFunc(serveEnv)})
+	}
+
+	if p.gcParamsRoute {
+		routes = append(routes, route{pattern: gcParamsPath, handler: http.HandlerFunc(p.serveGCParams)})
+	}
+
+	routes = append(routes,
+		route{pattern: "/debug/pprof/profile", handler: p.cpuProfile()},
+		route{pattern: "/debug/pprof/trace", handler: p.trace()},
+		route{pattern: "/debug/pprof/cmdline", handler: http.HandlerFunc(p.serveCmdline)},
+		route{pattern: "/debug/vars", handler: http.HandlerFunc(p.serveVars)},
+	)
+
+	for _, name := range deltaProfiles {
+		routes = append(routes, route{pattern: "/debug/pprof/" + name, handler: p.deltaProfile(name)})
+	}
+
+	return routes
+}
+
+// RegisteredRoutes returns the sorted patterns served by the pprof endpoint for the current options including
+// the path prefix, disabled routes are not returned. It returns nil if the handler is built by the mux factory.
+func (p *Profiler) RegisteredRoutes() []string {
+	if p.muxFactory != nil {
+		return nil
+	}
+
+	routes := p.routes()
+	patterns := make([]string, 0, len(routes))
+
+	for _, r := range routes {
+		if !r.disabled {
+			patterns = append(patterns, p.pathPrefix+r.pattern)
+		}
+	}
+
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+// WithPathPrefix serves all routes of the pprof endpoint below the prefix, e.g. "/admin" serves the index
+// of the profiles on /admin/debug/pprof/. The links of the index are relative, the profiles registered by the
+// application with pprof.NewProfile are listed and served below the prefix as well.
+// Requests outside of the prefix are answered with 404 Not Found.
+func WithPathPrefix(prefix string) Opt {
+	return func(p *Profiler) {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+
+		p.pathPrefix = prefix
+	}
+}
+
+// WithMuxFactory replaces the handler of the pprof endpoint by the handler returned by the factory,
+// which is called for every activation. The Profiler only activates and shuts down the endpoint:
+// neither the routes, the path prefix nor the middlewares (e.g. WithAuthFunc, WithAccessLog) are applied.
+func WithMuxFactory(factory func() http.Handler) Opt {
+	return func(p *Profiler) {
+		p.muxFactory = factory
+	}
+}
+
+// mux returns the handler for the pprof endpoint
+func (p *Profiler) mux() http.Handler {
+	if p.muxFactory != nil {
+		return p.muxFactory()
+	}
+
+	mux := http.NewServeMux()
+
+	for _, r := range p.routes() {
+		mux.Handle(r.pattern, r.handler)
+	}
+
+	h := p.countBytes(p.middleware(mux))
+	if p.pathPrefix != "" {
+		h = http.StripPrefix(p.pathPrefix, h)
+	}
+
+	return h
+}
@@ -0,0 +1,202 @@
+package profiler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithExtraHandler(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(
+		WithEventHandler(r.handle),
+		WithExtraHandler("/debug/app", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("app"))
+		})),
+		WithExtraHandler("/debug/panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("broken handler")
+		})),
+	)
+	h := p.mux()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/app", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "app", rec.Body.String())
+
+	// the panic is recovered
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, 1, r.count(MsgHandlerPanic))
+}
+
+func TestRegisteredRoutes(t *testing.T) {
+	routes := New().RegisteredRoutes()
+	assert.True(t, sort.StringsAreSorted(routes))
+	assert.Contains(t, routes, "/debug/pprof/profile")
+	assert.NotContains(t, routes, bundlePath)
+
+	routes = New(
+		WithBundle(true),
+		WithExtraHandler("/debug/app", http.NotFoundHandler()),
+	).RegisteredRoutes()
+	assert.Contains(t, routes, bundlePath)
+	assert.Contains(t, routes, "/debug/app")
+	assert.True(t, sort.StringsAreSorted(routes))
+}
+
+func TestWithDisabledRoutes(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(
+		WithEventHandler(r.handle),
+		WithDisabledRoutes("/debug/pprof/cmdline", "/debug/pprof/profile", "/debug/unknown"),
+	)
+	h := p.mux()
+
+	for _, path := range []string{"/debug/pprof/cmdline", "/debug/pprof/profile"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		assert.NotContains(t, p.RegisteredRoutes(), path)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// unknown patterns are reported
+	assert.Equal(t, 1, r.count(MsgUnknownRoute))
+}
+
+func TestWithEnabledRoutes(t *testing.T) {
+	p := New(
+		WithEnabledRoutes("/debug/pprof/heap", "/debug/pprof/goroutine", "/debug/vars"),
+		WithDisabledRoutes("/debug/vars"),
+	)
+	assert.Equal(t, []string{"/debug/pprof/goroutine", "/debug/pprof/heap"}, p.RegisteredRoutes())
+
+	h := p.mux()
+
+	tt := []struct {
+		path     string
+		expected int
+	}{
+		{"/debug/pprof/heap", http.StatusOK},
+		{"/debug/pprof/goroutine", http.StatusOK},
+		{"/debug/pprof/", http.StatusNotFound},
+		{"/debug/pprof/cmdline", http.StatusNotFound},
+		{"/debug/vars", http.StatusNotFound},
+		{"/other", http.StatusNotFound},
+	}
+
+	for _, tc := range tt {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		assert.Equal(t, tc.expected, rec.Code, tc.path)
+	}
+}
+
+func TestExtraHandlerConflict(t *testing.T) {
+	tt := []struct {
+		pattern  string
+		conflict bool
+	}{
+		{"/", true},
+		{"/debug/pprof/", true},
+		{"/debug/pprof/custom", true},
+		{"/debug/vars", true},
+		{"/debug/bundle", true},
+		{"/debug/snapshots/", true},
+		{"/debug/pprofiles", false},
+		{"/debug/app", false},
+	}
+
+	for _, tc := range tt {
+		_, err := NewWithError(WithExtraHandler(tc.pattern, http.NotFoundHandler()))
+		assert.Equal(t, tc.conflict, errors.Is(err, ErrRouteConflict), tc.pattern)
+	}
+
+	r := &eventRecorder{}
+	p := New(
+		WithEventHandler(r.handle),
+		WithExtraHandler("/debug/app", http.NotFoundHandler()),
+		WithExtraHandler("/debug/app", http.NotFoundHandler()),
+		WithExtraHandler("/debug/pprof/profile", http.NotFoundHandler()),
+	)
+	assert.Equal(t, 2, r.count(MsgRouteConflict))
+
+	// the conflicting handlers are not served
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestWithMuxFactory(t *testing.T) {
+	calls := 0
+	p := New(
+		WithAuthFunc(func(*http.Request) bool { return false }),
+		WithMuxFactory(func() http.Handler {
+			calls++
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/debug/custom", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("custom"))
+			})
+
+			return mux
+		}),
+	)
+
+	h := p.mux()
+	assert.Equal(t, 1, calls)
+	assert.Nil(t, p.RegisteredRoutes())
+
+	// the middlewares are not applied
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/custom", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "custom", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestWithPathPrefix(t *testing.T) {
+	// a custom profile registered by the application
+	name := "profiler_test/sessions"
+
+	custom := pprof.Lookup(name)
+	if custom == nil {
+		custom = pprof.NewProfile(name)
+	}
+
+	custom.Add(t, 0)
+	defer custom.Remove(t)
+
+	p := New(WithPathPrefix("admin/"))
+	assert.Contains(t, p.RegisteredRoutes(), "/admin/debug/pprof/")
+
+	for path, want := range map[string]int{
+		"/admin/debug/pprof/":                     http.StatusOK,
+		"/admin/debug/pprof/" + name + "?debug=1": http.StatusOK,
+		"/admin/debug/pprof/goroutine?debug=1":    http.StatusOK,
+		"/debug/pprof/":                           http.StatusNotFound,
+		"/debug/pprof/" + name + "?debug=1":       http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, rec.Code, path)
+
+		if path == "/admin/debug/pprof/" {
+			// the index links the custom profile relative to the prefixed path
+			assert.Contains(t, rec.Body.String(), "href='"+name+"?debug=1'")
+		}
+	}
+}
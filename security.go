@@ -0,0 +1,94 @@
+package profiler
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// securityCSP is the Content-Security-Policy of HTML responses
+//
+// The pprof index page uses inline styles, the policy allows them (and inline scripts of dashboards
+// served with WithExtraHandler) from the endpoint itself, including websocket connections back to it.
+const securityCSP = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self' ws: wss:; frame-ancestors 'none'"
+
+// WithSecurityHeaders sets the headers X-Content-Type-Options: nosniff, X-Frame-Options: DENY and
+// Referrer-Policy: no-referrer on the responses of the pprof endpoint, HTML responses get a Content-Security-Policy
+// in addition. Headers set with WithResponseHeaders or by the handler are not replaced.
+func WithSecurityHeaders(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.securityHeaders = enabled
+	}
+}
+
+// securityHeaders sets the security headers of the response
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setDefaultHeader(w.Header(), "X-Content-Type-Options", "nosniff")
+		setDefaultHeader(w.Header(), "X-Frame-Options", "DENY")
+		setDefaultHeader(w.Header(), "Referrer-Policy", "no-referrer")
+
+		next.ServeHTTP(&securityWriter{ResponseWriter: w}, r)
+	})
+}
+
+// setDefaultHeader sets the header if it is not set yet
+func setDefaultHeader(h http.Header, key, value string) {
+	if h.Get(key) == "" {
+		h.Set(key, value)
+	}
+}
+
+// securityWriter sets the Content-Security-Policy header if the response is HTML
+type securityWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// setCSP sets the Content-Security-Policy for HTML responses before the header is written,
+// the content type of a response without Content-Type header is detected from the first bytes written
+func (w *securityWriter) setCSP(b []byte) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	ct := w.Header().Get("Content-Type")
+	if ct == "" && b != nil {
+		ct = http.DetectContentType(b)
+	}
+
+	if strings.HasPrefix(ct, "text/html") {
+		setDefaultHeader(w.Header(), "Content-Security-Policy", securityCSP)
+	}
+}
+
+func (w *securityWriter) WriteHeader(status int) {
+	w.setCSP(nil)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *securityWriter) Write(b []byte) (int, error) {
+	w.setCSP(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer does
+func (w *securityWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does
+func (w *securityWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// Push implements http.Pusher if the underlying writer does
+func (w *securityWriter) Push(target string, opts *http.PushOptions) error {
+	return push(w.ResponseWriter, target, opts)
+}
@@ -0,0 +1,44 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSecurityHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New().mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Empty(t, rec.Header().Get("Referrer-Policy"))
+
+	p := New(
+		WithSecurityHeaders(true),
+		WithResponseHeaders(http.Header{"X-Frame-Options": []string{"SAMEORIGIN"}}),
+		WithExtraHandler("/debug/dashboard", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("<!DOCTYPE html><html><script>new WebSocket('ws://' + location.host)</script></html>"))
+		})),
+	)
+
+	for path, html := range map[string]bool{
+		"/debug/pprof/":          true,
+		"/debug/dashboard":       true,
+		"/debug/pprof/cmdline":   false,
+		"/debug/pprof/goroutine": false,
+	} {
+		rec := httptest.NewRecorder()
+		p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"), path)
+		assert.Equal(t, "SAMEORIGIN", rec.Header().Get("X-Frame-Options"), path)
+		assert.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"), path)
+
+		if html {
+			assert.Equal(t, securityCSP, rec.Header().Get("Content-Security-Policy"), path)
+		} else {
+			assert.Empty(t, rec.Header().Get("Content-Security-Policy"), path)
+		}
+	}
+}
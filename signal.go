@@ -0,0 +1,134 @@
+package profiler
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// Results of a received signal reported by the events
+const (
+	signalActivated = "activated"
+	signalIgnored   = "ignored"
+	signalDrained   = "drained"
+	signalCanceled  = "canceled"
+)
+
+// WithSignalBufferSize sets the buffer size of the signal channel (default: 1)
+//
+// Signals are delivered to the channel without blocking (see os/signal.Notify): signals arriving
+// while the buffer is full are dropped. The buffered signals are discarded when the pprof endpoint starts.
+// Every received signal is reported by an event, whether it activated the pprof endpoint or was ignored.
+func WithSignalBufferSize(n int) Opt {
+	return func(p *Profiler) {
+		if n > 0 {
+			p.signalBuffer = n
+		}
+	}
+}
+
+// newSignalChannel returns the channel receiving the signals
+func (p *Profiler) newSignalChannel() chan os.Signal {
+	return make(chan os.Signal, p.signalBuffer)
+}
+
+// ignoreSignals discards the buffered signals
+func (p *Profiler) ignoreSignals(c chan os.Signal) {
+	for {
+		select {
+		case s := <-c:
+			p.evt(InfoEvent, CodeSignalReceived, MsgSignalReceived, "signal", s, "result", signalIgnored)
+		default:
+			return
+		}
+	}
+}
+
+// disableSignals stop receiving of signals and drain the signal channel
+func disableSignals(c chan os.Signal) {
+	signal.Stop(c)
+	// drain all buffered signals, a stale signal must not activate a subsequent handler
+	for {
+		select {
+		case <-c:
+		default:
+			return
+		}
+	}
+}
+
+// WithSignalOnce stops the signal handler after the first activation, when the pprof endpoint is shutdown,
+// e.g. for one-shot debugging in a batch job. The signal is released then and the handler can be started again.
+func WithSignalOnce(once bool) Opt {
+	return func(p *Profiler) {
+		p.signalOnce = once
+	}
+}
+
+// WithSignalName sets the signal to activate the pprof endpoint by its name, e.g. "SIGUSR2" or "USR2"
+// (case-insensitive). NewWithError returns ErrUnknownSignal for a name which is not supported on the platform,
+// New keeps the previous signal then and emits an ErrorEvent.
+func WithSignalName(name string) Opt {
+	return func(p *Profiler) {
+		sig, err := signalByName(name)
+		if err != nil {
+			p.signalErr = err
+			return
+		}
+
+		p.signal = sig
+		p.signalErr = nil
+	}
+}
+
+// checkSignal returns ErrUnsupportedSignal if the signal can not be delivered on the platform
+func checkSignal(sig os.Signal) error {
+	if sig == nil || signalSupported(sig) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: signal %s not supported on %s, use Trigger", ErrUnsupportedSignal, signalName(sig), runtime.GOOS)
+}
+
+// checkTerminationSignal returns ErrTerminationSignal if the signal is used to terminate the process (SIGINT,
+// SIGTERM), the signal handler would catch it and the process would no longer shutdown on it
+func checkTerminationSignal(sig os.Signal) error {
+	if sig != os.Interrupt && sig != syscall.SIGTERM {
+		return nil
+	}
+
+	return fmt.Errorf("%w: signal %s activates the pprof endpoint instead of terminating the process",
+		ErrTerminationSignal, signalName(sig))
+}
+
+// signalByName returns the signal with the name
+func signalByName(name string) (os.Signal, error) {
+	n := strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(n, "SIG") {
+		n = "SIG" + n
+	}
+
+	for sig, sigName := range signalNames {
+		if sigName == n {
+			return sig, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownSignal, name)
+}
+
+// signalName returns the name of the signal, e.g. "SIGUSR1" instead of "user defined signal 1"
+func signalName(sig os.Signal) string {
+	if sig == nil {
+		return ""
+	}
+
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+
+	return sig.String()
+}
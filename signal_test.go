@@ -0,0 +1,153 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSignalBufferSize(t *testing.T) {
+	assert.Equal(t, 1, cap(New().newSignalChannel()))
+	assert.Equal(t, 5, cap(New(WithSignalBufferSize(5)).newSignalChannel()))
+	assert.Equal(t, 1, cap(New(WithSignalBufferSize(0)).newSignalChannel()))
+}
+
+func TestStartDelayStop(t *testing.T) {
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithStartDelay(time.Hour),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+	)
+
+	p.Start()
+	<-p.Started()
+	sendSignal(t, p)
+	<-c.timers
+
+	// the delay does not hold up the stop
+	p.Stop()
+}
+
+func TestWithSignalOnce(t *testing.T) {
+	r := &eventRecorder{}
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithEventHandler(r.handle),
+		WithSignalOnce(true),
+	)
+
+	timer := openWindow(t, p, c)
+
+	p.Lock()
+	done := p.done
+	p.Unlock()
+
+	timer.fire()
+	<-done
+
+	assert.Equal(t, 1, r.count(MsgHandlerStopped))
+	assert.True(t, errors.Is(p.Trigger(context.Background()), ErrNotRunning), "handler stopped after the first activation")
+
+	// the handler can be started again
+	require.NoError(t, p.StartWithError())
+	<-p.Started()
+	p.Stop()
+}
+
+func TestDisableSignals(t *testing.T) {
+	c := make(chan os.Signal, 3)
+	for i := 0; i < cap(c); i++ {
+		c <- os.Interrupt
+	}
+
+	disableSignals(c)
+	assert.Empty(t, c)
+}
+
+func TestUnknownSignalName(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle), WithSignalName("SIGFOO"))
+	assert.Equal(t, defaultSignal, p.signal)
+	assert.Equal(t, 1, r.count(MsgInvalidOption))
+
+	// a valid name replaces the unknown one
+	r = &eventRecorder{}
+	_ = New(WithEventHandler(r.handle), WithSignalName("SIGFOO"), WithSignalName("SIGTERM"))
+	assert.Equal(t, 0, r.count(MsgInvalidOption))
+
+	// the last signal option wins
+	p, err := NewWithError(WithSignalName("SIGFOO"), WithSignal(os.Interrupt))
+	require.NoError(t, err)
+	assert.Equal(t, os.Interrupt, p.signal)
+}
+
+type unsupportedSignal struct{}
+
+func (unsupportedSignal) String() string { return "SIGFAKE" }
+func (unsupportedSignal) Signal()        {}
+
+func TestUnsupportedSignal(t *testing.T) {
+	r := &eventRecorder{}
+	_ = New(WithEventHandler(r.handle), WithSignal(unsupportedSignal{}))
+	assert.Equal(t, 1, r.count(MsgSignalUnsupported))
+
+	_, err := NewWithError(WithSignal(unsupportedSignal{}))
+	assert.True(t, errors.Is(err, ErrUnsupportedSignal))
+	assert.Contains(t, err.Error(), "signal SIGFAKE not supported on "+runtime.GOOS)
+
+	_, err = NewWithError(WithSignal(testSignal))
+	assert.NoError(t, err)
+}
+
+func TestTerminationSignal(t *testing.T) {
+	for _, sig := range []os.Signal{syscall.SIGTERM, os.Interrupt} {
+		r := &eventRecorder{}
+		_ = New(WithEventHandler(r.handle), WithSignal(sig))
+		assert.Equal(t, 1, r.count(MsgTerminationSignal), sig)
+
+		assert.Panics(t, func() {
+			_ = New(WithStrict(true), WithSignal(sig))
+		})
+	}
+
+	r := &eventRecorder{}
+	_ = New(WithEventHandler(r.handle), WithSignal(testSignal))
+	assert.Equal(t, 0, r.count(MsgTerminationSignal))
+
+	// a trigger-only configuration is valid in strict mode on every platform
+	assert.NotPanics(t, func() {
+		_ = New(WithStrict(true), WithSignal(nil))
+	})
+
+	err := checkTerminationSignal(syscall.SIGTERM)
+	assert.True(t, errors.Is(err, ErrTerminationSignal))
+	assert.Contains(t, err.Error(), "SIGTERM")
+}
+
+func TestTriggerOnly(t *testing.T) {
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle), WithAddress(freeAddress(t)), WithSignal(nil))
+	assert.Equal(t, 0, r.count(MsgSignalUnsupported))
+
+	_, err := NewWithError(WithSignal(nil))
+	assert.NoError(t, err)
+
+	p.Start()
+	<-p.Started()
+
+	require.NoError(t, p.TriggerAndWait(context.Background()))
+
+	p.Stop()
+}
@@ -0,0 +1,159 @@
+//go:build !windows
+// +build !windows
+
+package profiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSignal is the activation signal of the tests
+// nolint: gochecknoglobals
+var testSignal os.Signal = syscall.SIGUSR2
+
+// sendSignal sends the test signal to the process
+func sendSignal(t *testing.T, _ *Profiler) {
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+}
+
+func TestDefaultSignal(t *testing.T) {
+	assert.Equal(t, syscall.SIGHUP, New().signal)
+}
+
+func TestSignalBufferDropSemantics(t *testing.T) {
+	tt := []struct {
+		size     int
+		expected int
+	}{
+		{1, 1},
+		{3, 3},
+	}
+
+	for _, tc := range tt {
+		sig := New(WithSignalBufferSize(tc.size)).newSignalChannel()
+		signal.Notify(sig, syscall.SIGUSR2)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+			time.Sleep(50 * time.Millisecond) // wait until the signal is delivered
+		}
+
+		signal.Stop(sig)
+		// signals arriving while the buffer is full are dropped
+		assert.Equal(t, tc.expected, len(sig), tc.size)
+	}
+}
+
+func TestSignalEvents(t *testing.T) {
+	c := newFakeClock()
+	results := make(chan interface{}, 10)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeSignalReceived {
+				results <- args[3]
+			}
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+	assert.Equal(t, signalActivated, <-results)
+
+	// the window is already open
+	sendSignal(t, p)
+	assert.Equal(t, signalIgnored, <-results)
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithDrainSignal(t *testing.T) {
+	c := newFakeClock()
+	hook := make(shutdownHook, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithDrainSignal(syscall.SIGUSR1),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithHooks(hook),
+	)
+
+	openWindow(t, p, c)
+
+	// the window is closed before the timer expires
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	<-hook
+
+	// the handler is still running, a signal could be discarded while the window closes
+	require.NoError(t, p.Trigger(context.Background()))
+
+	timer := <-c.timers
+	<-p.WindowOpen()
+
+	timer.fire()
+	<-hook
+	p.Stop()
+}
+
+func TestWithStartDelay(t *testing.T) {
+	c := newFakeClock()
+	results := make(chan interface{}, 10)
+	p := New(
+		WithSignal(testSignal),
+		WithDrainSignal(syscall.SIGUSR1),
+		WithStartDelay(time.Second),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeSignalReceived {
+				results <- args[3]
+			}
+		}),
+	)
+
+	p.Start()
+	<-p.Started()
+
+	// the activation is canceled during the delay
+	sendSignal(t, p)
+	assert.Equal(t, signalActivated, <-results)
+	<-c.timers
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Equal(t, signalCanceled, <-results)
+
+	// the endpoint is started after the delay
+	sendSignal(t, p)
+	assert.Equal(t, signalActivated, <-results)
+	(<-c.timers).fire()
+
+	timer := <-c.timers
+	<-p.WindowOpen()
+
+	timer.fire()
+	p.Stop()
+}
+
+func TestWithSignalName(t *testing.T) {
+	for _, name := range []string{"SIGUSR2", "usr2", " SigUsr2 "} {
+		p, err := NewWithError(WithSignalName(name))
+		require.NoError(t, err, name)
+		assert.Equal(t, syscall.SIGUSR2, p.signal, name)
+	}
+
+	_, err := NewWithError(WithSignalName("SIGFOO"))
+	assert.True(t, errors.Is(err, ErrUnknownSignal))
+
+	// New keeps the default signal
+	assert.Equal(t, defaultSignal, New(WithSignalName("SIGFOO")).signal)
+}
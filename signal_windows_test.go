@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package profiler
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSignal is the activation signal of the tests, a signal can not be sent to a running process on
+// windows, the tests activate the pprof endpoint with Trigger only
+// nolint: gochecknoglobals
+var testSignal os.Signal
+
+// sendSignal activates the pprof endpoint with Trigger, it replaces the test signal on windows
+func sendSignal(t *testing.T, p *Profiler) {
+	require.NoError(t, p.Trigger(context.Background()))
+}
+
+func TestDefaultSignal(t *testing.T) {
+	require.Nil(t, New().signal)
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package profiler
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames are the names of the signals commonly used to activate the pprof endpoint
+// nolint: gochecknoglobals
+var signalNames = map[os.Signal]string{
+	syscall.SIGHUP:   "SIGHUP",
+	syscall.SIGINT:   "SIGINT",
+	syscall.SIGQUIT:  "SIGQUIT",
+	syscall.SIGTERM:  "SIGTERM",
+	syscall.SIGUSR1:  "SIGUSR1",
+	syscall.SIGUSR2:  "SIGUSR2",
+	syscall.SIGWINCH: "SIGWINCH",
+	syscall.SIGPROF:  "SIGPROF",
+}
+
+// defaultSignal is the default activation signal
+// nolint: gochecknoglobals
+var defaultSignal os.Signal = syscall.SIGHUP
+
+// signalSupported reports whether the signal can be delivered on the platform
+func signalSupported(sig os.Signal) bool {
+	_, ok := sig.(syscall.Signal)
+	return ok
+}
@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package profiler
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames are the names of the signals commonly used to activate the pprof endpoint
+// nolint: gochecknoglobals
+var signalNames = map[os.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGTERM: "SIGTERM",
+}
+
+// defaultSignal is the default activation signal, windows has no signal which can be sent to a
+// running process without terminating it, the pprof endpoint is activated with Trigger only
+// nolint: gochecknoglobals
+var defaultSignal os.Signal
+
+// signalSupported reports whether the signal can be delivered on the platform,
+// on windows only Ctrl-C / Ctrl-Break (SIGINT) and the close, logoff and shutdown events (SIGTERM) are delivered
+func signalSupported(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM
+}
@@ -0,0 +1,141 @@
+package profiler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotsPath is the route of the heap snapshots
+const snapshotsPath = "/debug/snapshots"
+
+// WithHeapSnapshots captures a heap profile every interval while the signal handler is running.
+// The last keep snapshots are retained and served on /debug/snapshots, e.g. to investigate
+// the history of a slow memory leak. /debug/snapshots lists the snapshots as JSON, a single
+// snapshot is served in the pprof format on /debug/snapshots/<id>. New ignores the option with an ErrorEvent
+// if the interval is not positive, NewWithError returns ErrInvalidInterval.
+func WithHeapSnapshots(interval time.Duration, keep int) Opt {
+	return func(p *Profiler) {
+		if keep <= 0 {
+			p.snapshots = nil
+			return
+		}
+
+		p.snapshotInterval = interval
+		p.snapshots = &snapshotRing{keep: keep}
+	}
+}
+
+// checkHeapSnapshots returns ErrInvalidInterval if the interval of the heap snapshots is not positive
+func (p *Profiler) checkHeapSnapshots() error {
+	if p.snapshots == nil || p.snapshotInterval > 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: heap snapshot interval %s", ErrInvalidInterval, p.snapshotInterval)
+}
+
+// heapSnapshot is a heap profile captured by the heap snapshots
+type heapSnapshot struct {
+	ID   uint64    `json:"id"`
+	Time time.Time `json:"time"`
+	Size int       `json:"size"`
+	data []byte
+}
+
+// snapshotRing retains the last snapshots
+type snapshotRing struct {
+	sync.Mutex
+	keep  int
+	next  uint64
+	items []heapSnapshot
+}
+
+// add appends a snapshot and drops the oldest snapshots exceeding the limit
+func (r *snapshotRing) add(t time.Time, data []byte) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.items = append(r.items, heapSnapshot{ID: r.next, Time: t, Size: len(data), data: data})
+	r.next++
+
+	if len(r.items) > r.keep {
+		r.items = append([]heapSnapshot(nil), r.items[len(r.items)-r.keep:]...)
+	}
+}
+
+// list returns the retained snapshots, oldest first
+func (r *snapshotRing) list() []heapSnapshot {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]heapSnapshot{}, r.items...)
+}
+
+// get returns the snapshot with the id
+func (r *snapshotRing) get(id uint64) (heapSnapshot, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, s := range r.items {
+		if s.ID == id {
+			return s, true
+		}
+	}
+
+	return heapSnapshot{}, false
+}
+
+// heapSnapshots captures the heap snapshots until the context is done
+func (p *Profiler) heapSnapshots(ctx context.Context) {
+	for {
+		timer := p.clock.NewTimer(p.snapshotInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		data, err := p.CaptureHeap()
+		if err != nil {
+			p.evt(WarnEvent, CodeProfileCaptureFailed, MsgProfileCaptureFailed, "kind", ProfileHeap, "error", err)
+			continue
+		}
+
+		p.snapshots.add(p.clock.Now(), data)
+	}
+}
+
+// serveSnapshots lists the heap snapshots or serves a single snapshot
+func (p *Profiler) serveSnapshots(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, snapshotsPath), "/")
+	if id == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(p.snapshots.list())
+
+		return
+	}
+
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		serveError(w, http.StatusBadRequest, "invalid snapshot id")
+		return
+	}
+
+	s, ok := p.snapshots.get(n)
+	if !ok {
+		serveError(w, http.StatusNotFound, "unknown snapshot")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="heap-%d.pprof"`, s.ID))
+	_, _ = w.Write(s.data)
+}
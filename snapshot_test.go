@@ -0,0 +1,76 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRing(t *testing.T) {
+	r := &snapshotRing{keep: 2}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		r.add(now, []byte{byte(i)})
+	}
+
+	l := r.list()
+	require.Len(t, l, 2)
+	assert.Equal(t, uint64(1), l[0].ID)
+	assert.Equal(t, uint64(2), l[1].ID)
+
+	_, ok := r.get(0)
+	assert.False(t, ok)
+
+	s, ok := r.get(2)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{2}, s.data)
+}
+
+func TestHeapSnapshots(t *testing.T) {
+	c := newFakeClock()
+	p := New(WithClock(c), WithHeapSnapshots(time.Minute, 1))
+
+	p.Start()
+	(<-c.timers).fire()
+	// the next timer is created after the snapshot was added
+	<-c.timers
+	p.Stop()
+
+	h := p.mux()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/snapshots", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var l []heapSnapshot
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&l))
+	require.Len(t, l, 1)
+	assert.Equal(t, uint64(0), l[0].ID)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/snapshots/0", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, gzipMagic, rec.Body.Bytes()[:2])
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/snapshots/1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHeapSnapshotsInterval(t *testing.T) {
+	_, err := NewWithError(WithHeapSnapshots(0, 3))
+	assert.True(t, errors.Is(err, ErrInvalidInterval))
+
+	// New ignores the heap snapshots
+	r := &eventRecorder{}
+	p := New(WithEventHandler(r.handle), WithHeapSnapshots(-time.Minute, 3))
+	assert.Nil(t, p.snapshots)
+	assert.Equal(t, 1, r.count(MsgInvalidOption))
+}
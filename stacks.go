@@ -0,0 +1,26 @@
+package profiler
+
+import (
+	"net/http"
+	"runtime/pprof"
+)
+
+// stacksPath is the route of the goroutine stack dump
+const stacksPath = "/debug/stacks"
+
+// WithStacksRoute enables the /debug/stacks route which serves the full stack dump of all goroutines
+// as plain text, like /debug/pprof/goroutine?debug=2
+func WithStacksRoute(enabled bool) Opt {
+	return func(p *Profiler) {
+		p.stacksRoute = enabled
+	}
+}
+
+// serveStacks writes the stack dump of all goroutines
+func serveStacks(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
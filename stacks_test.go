@@ -0,0 +1,23 @@
+package profiler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStacksRoute(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New().mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, stacksPath, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	New(WithStacksRoute(true)).mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, stacksPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	// debug=2 dumps the goroutines in the format of an unrecovered panic
+	assert.Contains(t, rec.Body.String(), "goroutine 1 [")
+	assert.Contains(t, rec.Body.String(), "TestWithStacksRoute")
+}
@@ -0,0 +1,56 @@
+package profiler
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ProfileStorage stores the profiles written by the Profiler (e.g. by WithAutoCapture), e.g. in a blob storage
+// if the local disk is not writable. The data read from r is in the pprof format.
+type ProfileStorage interface {
+	Store(name string, r io.Reader) error
+}
+
+// ProfileStorageFunc is an adapter to use a function as ProfileStorage
+type ProfileStorageFunc func(name string, r io.Reader) error
+
+// Store calls f(name, r)
+func (f ProfileStorageFunc) Store(name string, r io.Reader) error {
+	return f(name, r)
+}
+
+// FileStorage is a ProfileStorage which writes the profiles as files to the directory
+type FileStorage string
+
+// Store writes the profile to the file name in the directory, an existing file is replaced
+func (s FileStorage) Store(name string, r io.Reader) error {
+	f, err := os.OpenFile(filepath.Join(string(s), name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WithProfileStorage sets the storage of the profiles written by the Profiler,
+// it takes precedence over the directory of WithAutoCapture
+func WithProfileStorage(s ProfileStorage) Opt {
+	return func(p *Profiler) {
+		p.storage = s
+	}
+}
+
+// profileStorage returns the storage of the profiles, without WithProfileStorage the files are written to dir
+func (p *Profiler) profileStorage(dir string) ProfileStorage {
+	if p.storage != nil {
+		return p.storage
+	}
+
+	return FileStorage(dir)
+}
@@ -0,0 +1,48 @@
+package profiler
+
+import "crypto/tls"
+
+// ReloadTLS loads the certificate and key from the PEM encoded files and replaces the certificate
+// of the pprof endpoint, new connections use the new certificate. The endpoint is served with TLS
+// from the next activation on if it was not configured with WithHTTPServer.
+//
+// Alternatively set the GetCertificate callback of the TLSConfig of WithHTTPServer to load rotated
+// certificates on every handshake. A certificate loaded by ReloadTLS takes precedence.
+func (p *Profiler) ReloadTLS(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	p.tlsCert.Store(&cert)
+
+	return nil
+}
+
+// certificate returns the certificate loaded by ReloadTLS or nil
+func (p *Profiler) certificate() *tls.Certificate {
+	cert, _ := p.tlsCert.Load().(*tls.Certificate)
+	return cert
+}
+
+// getCertificate returns the current certificate loaded by ReloadTLS, see tls.Config.GetCertificate
+func (p *Profiler) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return p.certificate(), nil
+}
+
+// reloadableTLS configures the server to use the certificate loaded by ReloadTLS
+func (p *Profiler) reloadableTLS(srv *tls.Config) *tls.Config {
+	if p.certificate() == nil {
+		return srv
+	}
+
+	if srv == nil {
+		srv = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	// GetCertificate is only used without Certificates or if the client sends SNI
+	srv.Certificates = nil
+	srv.GetCertificate = p.getCertificate
+
+	return srv
+}
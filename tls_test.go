@@ -0,0 +1,73 @@
+package profiler
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes the certificate and key as PEM files to the directory
+func writeCertificate(t *testing.T, dir, name string, cert tls.Certificate) (certFile, keyFile string) {
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+
+	require.NoError(t, ioutil.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600))
+
+	return certFile, keyFile
+}
+
+// peerCertificate returns the certificate presented by the server
+func peerCertificate(t *testing.T, address string) []byte {
+	conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true}) // nolint: gosec
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].Raw
+}
+
+func TestReloadTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiler")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	first, second := testCertificate(t), testCertificate(t)
+	firstCert, firstKey := writeCertificate(t, dir, "first", first)
+	secondCert, secondKey := writeCertificate(t, dir, "second", second)
+
+	c := newFakeClock()
+	address := freeAddress(t)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(address),
+		WithClock(c),
+	)
+
+	assert.Error(t, p.ReloadTLS(filepath.Join(dir, "missing.crt"), firstKey))
+	require.NoError(t, p.ReloadTLS(firstCert, firstKey))
+
+	timer := openWindow(t, p, c)
+	assert.Equal(t, first.Certificate[0], peerCertificate(t, address))
+
+	// the rotated certificate is used by new connections of the open endpoint
+	require.NoError(t, p.ReloadTLS(secondCert, secondKey))
+	assert.Equal(t, second.Certificate[0], peerCertificate(t, address))
+
+	timer.fire()
+	p.Stop()
+}
@@ -0,0 +1,261 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithAutoTrigger activates the pprof endpoint when the condition returns true, e.g. when the heap
+// in-use bytes exceed a threshold. The condition is evaluated every interval while the signal handler is running.
+// New ignores the auto trigger with an ErrorEvent if the interval is not positive, NewWithError returns
+// ErrInvalidInterval.
+func WithAutoTrigger(cond func() bool, interval time.Duration) Opt {
+	return func(p *Profiler) {
+		p.autoTriggerCond = cond
+		p.autoTriggerInterval = interval
+	}
+}
+
+// WithAutoTriggerLimit limits the number of activations by the auto trigger to max per duration
+//
+// Independent of the limit, the auto trigger only activates the pprof endpoint again
+// after the condition returned false in between. New ignores the auto trigger with an ErrorEvent if the period
+// is not positive, NewWithError returns ErrInvalidInterval.
+func WithAutoTriggerLimit(max int, per time.Duration) Opt {
+	return func(p *Profiler) {
+		p.autoTriggerMax = max
+		p.autoTriggerPer = per
+	}
+}
+
+// checkAutoTrigger returns ErrInvalidInterval if the interval of the auto trigger or the period of its limit
+// is not positive
+func (p *Profiler) checkAutoTrigger() error {
+	if p.autoTriggerCond != nil && p.autoTriggerInterval <= 0 {
+		return fmt.Errorf("%w: auto trigger interval %s", ErrInvalidInterval, p.autoTriggerInterval)
+	}
+
+	if p.autoTriggerMax > 0 && p.autoTriggerPer <= 0 {
+		return fmt.Errorf("%w: auto trigger limit period %s", ErrInvalidInterval, p.autoTriggerPer)
+	}
+
+	return nil
+}
+
+// TriggerOpt is an option of an activation by Trigger or TriggerAndWait
+type TriggerOpt func(*activationRequest)
+
+// TriggerReason sets the reason of the activation (e.g. "investigating OOM ticket #123"), it is added to the
+// events of the activation, passed to the hooks (see ReasonFromContext) and reported in the WindowStats
+func TriggerReason(reason string) TriggerOpt {
+	return func(r *activationRequest) {
+		r.reason = reason
+	}
+}
+
+// Trigger activates the pprof endpoint like the signal, e.g. on platforms without the signal
+//
+// Trigger blocks until the signal handler accepts the activation, while the pprof endpoint is open
+// it waits until the endpoint is shutdown. It returns ErrNotRunning if the signal handler is not running
+// or is stopped in the meantime and the error of the context if it is done before the activation is accepted.
+func (p *Profiler) Trigger(ctx context.Context, opts ...TriggerOpt) error {
+	return p.requestActivation(ctx, newActivationRequest(nil, opts))
+}
+
+// TriggerAndWait activates the pprof endpoint like Trigger and waits until it is serving, e.g. to send requests
+// in a test without a sleep. It returns ErrNotRunning if the signal handler is not running, ErrWindowFailed if
+// the endpoint fails to start or the signal handler is stopped in the meantime and the error of the context
+// if it is done before.
+func (p *Profiler) TriggerAndWait(ctx context.Context, opts ...TriggerOpt) error {
+	ready := make(chan error, 1)
+
+	if err := p.requestActivation(ctx, newActivationRequest(ready, opts)); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-ready:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestActivation passes the activation request to the signal handler
+func (p *Profiler) requestActivation(ctx context.Context, req activationRequest) error {
+	p.Lock()
+	running, done := p.running, p.done
+	p.Unlock()
+
+	if !running {
+		return ErrNotRunning
+	}
+
+	select {
+	case p.trigger <- req:
+		return nil
+	case <-done:
+		return ErrNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// activationRequest is an activation of the pprof endpoint by a Trigger function
+type activationRequest struct {
+	reason string
+	// ready receives the result of an activation by TriggerAndWait
+	ready chan<- error
+}
+
+// newActivationRequest returns an activation request with the options applied
+func newActivationRequest(ready chan<- error, opts []TriggerOpt) activationRequest {
+	req := activationRequest{ready: ready}
+	for _, opt := range opts {
+		opt(&req)
+	}
+
+	return req
+}
+
+// fail reports an activation which did not open the pprof endpoint to TriggerAndWait
+func (r activationRequest) fail() {
+	if r.ready != nil {
+		r.ready <- ErrWindowFailed
+	}
+}
+
+// reasonKey is the context key of the activation reason passed to the hooks
+type reasonKey struct{}
+
+// ReasonFromContext returns the reason of the activation passed to a HookerContext, see TriggerReason
+func ReasonFromContext(ctx context.Context) (string, bool) {
+	reason, ok := ctx.Value(reasonKey{}).(string)
+	return reason, ok && reason != ""
+}
+
+// reason returns the reason of the current activation
+func (p *Profiler) reason() string {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.activationReason
+}
+
+// withReason appends the reason of the current activation to the args of an event
+func (p *Profiler) withReason(args ...interface{}) []interface{} {
+	if reason := p.reason(); reason != "" {
+		args = append(args, "reason", reason)
+	}
+
+	return args
+}
+
+// notifyReady sends nil to ready when the window channel open of an activation is closed
+// or ErrWindowFailed if the activation ends before
+func notifyReady(ready chan<- error, open, closed <-chan struct{}) {
+	select {
+	case <-open:
+		ready <- nil
+	case <-closed:
+		// the window may have opened and closed in the meantime
+		select {
+		case <-open:
+			ready <- nil
+		default:
+			ready <- ErrWindowFailed
+		}
+	}
+}
+
+// activate requests the activation of the pprof endpoint
+// It returns false if the signal handler is not waiting for an activation, e.g. the endpoint is already open.
+func (p *Profiler) activate() bool {
+	select {
+	case p.trigger <- activationRequest{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// autoTrigger evaluates the auto trigger condition until the context is done
+func (p *Profiler) autoTrigger(ctx context.Context) {
+	state := &autoTriggerState{
+		armed: true,
+		max:   p.autoTriggerMax,
+		per:   p.autoTriggerPer,
+	}
+
+	for {
+		timer := p.clock.NewTimer(p.autoTriggerInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		switch state.evaluate(p.clock.Now(), p.autoTriggerCond()) {
+		case autoTriggerFire:
+			if p.activate() {
+				state.fired(p.clock.Now())
+				p.evt(InfoEvent, CodeAutoTriggered, MsgAutoTriggered)
+			}
+		case autoTriggerSuppressed:
+			p.evt(WarnEvent, CodeAutoTriggerSuppressed, MsgAutoTriggerSuppressed, "max", state.max, "per", state.per)
+		case autoTriggerNone:
+		}
+	}
+}
+
+// autoTriggerResult is the result of an evaluation of the auto trigger
+type autoTriggerResult int
+
+const (
+	autoTriggerNone autoTriggerResult = iota
+	autoTriggerFire
+	autoTriggerSuppressed
+)
+
+// autoTriggerState debounces and limits the activations of the auto trigger
+type autoTriggerState struct {
+	armed bool
+	max   int
+	per   time.Duration
+	fires []time.Time
+}
+
+// evaluate returns whether the auto trigger fires for the result of the condition
+func (s *autoTriggerState) evaluate(now time.Time, cond bool) autoTriggerResult {
+	if !cond {
+		s.armed = true
+		return autoTriggerNone
+	}
+
+	if !s.armed {
+		return autoTriggerNone
+	}
+
+	if s.max > 0 {
+		// forget the activations outside of the limit period
+		for len(s.fires) > 0 && now.Sub(s.fires[0]) >= s.per {
+			s.fires = s.fires[1:]
+		}
+
+		if len(s.fires) >= s.max {
+			s.armed = false
+			return autoTriggerSuppressed
+		}
+	}
+
+	return autoTriggerFire
+}
+
+// fired records an activation of the auto trigger
+func (s *autoTriggerState) fired(now time.Time) {
+	s.armed = false
+	s.fires = append(s.fires, now)
+}
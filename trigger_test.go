@@ -0,0 +1,245 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAutoTrigger(t *testing.T) {
+	p := New(WithAutoTrigger(func() bool { return true }, time.Second))
+	assert.NotNil(t, p.autoTriggerCond)
+	assert.Equal(t, time.Second, p.autoTriggerInterval)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, err := NewWithError(WithAutoTrigger(func() bool { return true }, interval))
+		assert.True(t, errors.Is(err, ErrInvalidInterval), interval)
+
+		// New ignores the auto trigger
+		r := &eventRecorder{}
+		p = New(WithEventHandler(r.handle), WithAutoTrigger(func() bool { return true }, interval))
+		assert.Nil(t, p.autoTriggerCond, interval)
+		assert.Equal(t, 1, r.count(MsgInvalidOption), interval)
+	}
+}
+
+func TestAutoTrigger(t *testing.T) {
+	var threshold int32
+
+	r := &eventRecorder{}
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithEventHandler(r.handle),
+		WithAutoTrigger(func() bool {
+			return atomic.LoadInt32(&threshold) > 0
+		}, 10*time.Millisecond),
+	)
+
+	open := p.WindowOpen()
+
+	p.Start()
+	<-p.Started()
+
+	select {
+	case <-open:
+		t.Fatal("window opened before the condition is met")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	atomic.StoreInt32(&threshold, 1)
+	<-open
+	p.Stop()
+
+	assert.Equal(t, 1, r.count(MsgAutoTriggered))
+}
+
+func TestWithAutoTriggerLimit(t *testing.T) {
+	p := New(WithAutoTriggerLimit(2, time.Hour))
+	assert.Equal(t, 2, p.autoTriggerMax)
+	assert.Equal(t, time.Hour, p.autoTriggerPer)
+
+	cond := WithAutoTrigger(func() bool { return true }, time.Second)
+	_, err := NewWithError(cond, WithAutoTriggerLimit(2, 0))
+	assert.True(t, errors.Is(err, ErrInvalidInterval))
+
+	r := &eventRecorder{}
+	p = New(WithEventHandler(r.handle), cond, WithAutoTriggerLimit(2, -time.Hour))
+	assert.Nil(t, p.autoTriggerCond)
+	assert.Equal(t, 1, r.count(MsgInvalidOption))
+}
+
+func TestAutoTriggerDebounce(t *testing.T) {
+	s := &autoTriggerState{armed: true}
+	now := time.Now()
+
+	assert.Equal(t, autoTriggerFire, s.evaluate(now, true))
+	s.fired(now)
+
+	// no activation while the condition stays true
+	assert.Equal(t, autoTriggerNone, s.evaluate(now, true))
+	assert.Equal(t, autoTriggerNone, s.evaluate(now, false))
+	assert.Equal(t, autoTriggerFire, s.evaluate(now, true))
+}
+
+func TestAutoTriggerLimit(t *testing.T) {
+	s := &autoTriggerState{armed: true, max: 2, per: time.Hour}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, autoTriggerFire, s.evaluate(now, true))
+		s.fired(now)
+		assert.Equal(t, autoTriggerNone, s.evaluate(now, false))
+	}
+
+	assert.Equal(t, autoTriggerSuppressed, s.evaluate(now, true))
+	// suppressed only once while the condition stays true
+	assert.Equal(t, autoTriggerNone, s.evaluate(now, true))
+	assert.Equal(t, autoTriggerNone, s.evaluate(now, false))
+
+	// the limit period passed
+	assert.Equal(t, autoTriggerFire, s.evaluate(now.Add(time.Hour), true))
+}
+
+func TestTrigger(t *testing.T) {
+	p := New(WithAddress(freeAddress(t)))
+	assert.True(t, errors.Is(p.Trigger(context.Background()), ErrNotRunning), "not running")
+
+	p.Start()
+	<-p.Started()
+
+	open := p.WindowOpen()
+	assert.NoError(t, p.Trigger(context.Background()))
+	<-open
+
+	p.Stop()
+	assert.True(t, errors.Is(p.Trigger(context.Background()), ErrNotRunning), "stopped")
+}
+
+func TestTriggerAndWait(t *testing.T) {
+	address := freeAddress(t)
+	p := New(WithAddress(address))
+	assert.True(t, errors.Is(p.TriggerAndWait(context.Background()), ErrNotRunning))
+
+	p.Start()
+	<-p.Started()
+
+	require.NoError(t, p.TriggerAndWait(context.Background()))
+
+	// the endpoint is serving, no sleep required
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/", address))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the window is still open, the activation is not accepted before the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, p.TriggerAndWait(ctx))
+
+	p.Stop()
+}
+
+func TestTriggerAndWaitFailed(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	p := New(WithAddress(l.Addr().String()), WithEventHandler(func(EventType, string, ...interface{}) {}))
+
+	p.Start()
+	<-p.Started()
+
+	assert.True(t, errors.Is(p.TriggerAndWait(context.Background()), ErrWindowFailed))
+
+	p.Stop()
+}
+
+// reasonHook records the activation reason passed to PreStart
+type reasonHook chan string
+
+func (h reasonHook) PreStart(ctx context.Context) {
+	reason, _ := ReasonFromContext(ctx)
+	h <- reason
+}
+
+func (reasonHook) PostShutdown(context.Context) {}
+
+func TestTriggerReason(t *testing.T) {
+	const reason = "investigating OOM ticket #123"
+
+	assert.True(t, errors.Is(New().Trigger(context.Background(), TriggerReason(reason)), ErrNotRunning))
+
+	var (
+		mu       sync.Mutex
+		listened []interface{}
+	)
+
+	c := newFakeClock()
+	reasons := make(reasonHook, 1)
+	stats := make(chan WindowStats, 1)
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithContextHooks(reasons),
+		WithOnWindowClose(func(s WindowStats) {
+			stats <- s
+		}),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeWindowOpened {
+				mu.Lock()
+				listened = args
+				mu.Unlock()
+			}
+		}),
+	)
+
+	timer := openWindowWith(t, p, c, func(t *testing.T, p *Profiler) {
+		require.NoError(t, p.Trigger(context.Background(), TriggerReason(reason)))
+	})
+
+	assert.Equal(t, reason, <-reasons)
+
+	mu.Lock()
+	assert.Equal(t, []interface{}{"reason", reason}, listened[len(listened)-2:])
+	mu.Unlock()
+
+	timer.fire()
+	assert.Equal(t, reason, (<-stats).ActivationReason)
+
+	// an activation without reason
+	open := p.WindowOpen()
+	require.NoError(t, p.Trigger(context.Background()))
+	assert.Equal(t, "", <-reasons)
+	<-open
+
+	timer = <-c.timers
+	timer.fire()
+	assert.Equal(t, "", (<-stats).ActivationReason)
+
+	// the reason of an activation which is waited for
+	done := make(chan error, 1)
+	go func() {
+		done <- p.TriggerAndWait(context.Background(), TriggerReason(reason))
+	}()
+
+	assert.Equal(t, reason, <-reasons)
+	timer = <-c.timers
+	require.NoError(t, <-done)
+	timer.fire()
+	assert.Equal(t, reason, (<-stats).ActivationReason)
+
+	p.Stop()
+}
@@ -0,0 +1,43 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Validate checks the configuration like NewWithError and in addition verifies a TLSConfig has a certificate
+// and the listen addresses can be bound, the listeners are closed immediately. It allows to detect
+// a misconfiguration in CI without starting the Profiler. Call Validate before Start, an open pprof endpoint
+// occupies its addresses. The served routes are returned by RegisteredRoutes.
+func (p *Profiler) Validate() error {
+	if err := p.checkConfig(); err != nil {
+		return err
+	}
+
+	if err := p.checkTLS(); err != nil {
+		return err
+	}
+
+	listeners, err := p.bindWithTimeout(context.Background())
+	if err != nil {
+		return err
+	}
+
+	closeListeners(listeners)
+
+	return nil
+}
+
+// checkTLS verifies the server can present a certificate if it is served with TLS
+func (p *Profiler) checkTLS() error {
+	if p.server == nil || p.server.TLSConfig == nil || p.certificate() != nil {
+		return nil
+	}
+
+	c := p.server.TLSConfig
+	if len(c.Certificates) == 0 && c.GetCertificate == nil && c.GetConfigForClient == nil {
+		return fmt.Errorf("%w: TLSConfig without certificate", ErrInvalidTLS)
+	}
+
+	return nil
+}
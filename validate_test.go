@@ -0,0 +1,62 @@
+package profiler
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	address := freeAddress(t)
+	require.NoError(t, New(WithAddress(address)).Validate())
+
+	// the listener is released
+	l, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	assert.True(t, errors.Is(New(WithAddress(address)).Validate(), ErrBindFailed))
+	assert.True(t, errors.Is(New(WithAddress("localhost")).Validate(), ErrInvalidAddress))
+	assert.True(t, errors.Is(New(
+		WithAddress(freeAddress(t)),
+		WithExtraHandler("/debug/pprof/heap", http.NotFoundHandler()),
+	).Validate(), ErrRouteConflict))
+
+	for name, tc := range map[string]struct {
+		config *tls.Config
+		err    error
+	}{
+		"without certificate": {config: &tls.Config{MinVersion: tls.VersionTLS12}, err: ErrInvalidTLS},
+		"with certificate": {config: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{testCertificate(t)},
+		}},
+	} {
+		err := New(WithAddress(freeAddress(t)), WithHTTPServer(&http.Server{TLSConfig: tc.config})).Validate()
+		if tc.err == nil {
+			assert.NoError(t, err, name)
+		} else {
+			assert.True(t, errors.Is(err, tc.err), name)
+		}
+	}
+}
+
+func TestValidatePortRange(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	p := New(WithAddress("127.0.0.1:0"), WithPortRange(port, port+10))
+	require.NoError(t, p.Validate())
+
+	// the dry-run does not change the configuration
+	assert.Equal(t, "127.0.0.1:0", p.Address())
+}
@@ -0,0 +1,95 @@
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// httpClientTimeout is the timeout of the default client for outbound requests, see WithHTTPClient
+const httpClientTimeout = 10 * time.Second
+
+// WithHTTPClient sets the client for the outbound requests of the Profiler, the activation webhook and the
+// readiness probe, e.g. to honor proxy settings, TLS configuration and timeouts. The default client times out
+// after 10s. The readiness probe must be able to reach the pprof endpoint with the client (e.g. trust its
+// certificate), a probe of a unix socket always uses an internal client.
+func WithHTTPClient(c *http.Client) Opt {
+	return func(p *Profiler) {
+		p.httpClient = c
+	}
+}
+
+// client returns the client for outbound requests
+func (p *Profiler) client() *http.Client {
+	if p.httpClient != nil {
+		return p.httpClient
+	}
+
+	return &http.Client{Timeout: httpClientTimeout}
+}
+
+// Activation is the payload posted to the activation webhook
+type Activation struct {
+	PID     int    `json:"pid"`
+	Host    string `json:"host"`
+	Address string `json:"address"`
+	Signal  string `json:"signal"`
+	Auth    bool   `json:"auth"`
+}
+
+// WithActivationWebhook posts an Activation as JSON to the url whenever the pprof endpoint is opened,
+// e.g. to alert a SIEM. The webhook is notified asynchronously: a failure emits a WarnEvent
+// and does not prevent the pprof endpoint from serving.
+func WithActivationWebhook(url string) Opt {
+	return func(p *Profiler) {
+		p.webhookURL = url
+	}
+}
+
+// notifyWebhook posts the activation of the pprof endpoint listening on addrs to the activation webhook
+func (p *Profiler) notifyWebhook(addrs []string) {
+	host, _ := os.Hostname()
+
+	body, err := json.Marshal(Activation{
+		PID:     os.Getpid(),
+		Host:    host,
+		Address: strings.Join(addrs, ","),
+		Signal:  signalName(p.signal),
+		Auth:    p.authFunc != nil,
+	})
+	if err != nil {
+		p.evt(WarnEvent, CodeWebhookFailed, MsgWebhookFailed, "url", p.webhookURL, "error", err)
+		return
+	}
+
+	if err := postWebhook(p.client(), p.webhookURL, body); err != nil {
+		p.evt(WarnEvent, CodeWebhookFailed, MsgWebhookFailed, "url", p.webhookURL, "error", err)
+	}
+}
+
+// postWebhook posts the JSON body to the url, every response but 2xx is an error
+func postWebhook(client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
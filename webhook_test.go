@@ -0,0 +1,113 @@
+package profiler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithActivationWebhook(t *testing.T) {
+	activations := make(chan Activation, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Activation
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+		activations <- a
+	}))
+
+	defer srv.Close()
+
+	addr := freeAddress(t)
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(addr),
+		WithClock(c),
+		WithActivationWebhook(srv.URL),
+	)
+
+	timer := openWindow(t, p, c)
+	a := <-activations
+
+	timer.fire()
+	p.Stop()
+
+	assert.Equal(t, os.Getpid(), a.PID)
+	assert.Equal(t, addr, a.Address)
+	assert.Equal(t, signalName(testSignal), a.Signal)
+	assert.False(t, a.Auth)
+}
+
+func TestActivationWebhookFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	defer srv.Close()
+
+	failed := make(chan struct{}, 1)
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithActivationWebhook(srv.URL),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, _ ...interface{}) {
+			if code == CodeWebhookFailed {
+				failed <- struct{}{}
+			}
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+	<-failed
+
+	// the failed webhook does not close the pprof endpoint
+	resp, err := http.Get("http://" + p.Address() + "/debug/pprof/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	timer.fire()
+	p.Stop()
+}
+
+type recordingTransport struct {
+	paths chan string
+}
+
+func (t recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.paths <- r.URL.Path
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	defer srv.Close()
+
+	transport := recordingTransport{paths: make(chan string, 2)}
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithReadinessProbe(true),
+		WithActivationWebhook(srv.URL+"/hook"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+	)
+
+	timer := openWindow(t, p, c)
+
+	// the readiness probe and the webhook use the client
+	assert.Equal(t, "/debug/pprof/", <-transport.paths)
+	assert.Equal(t, "/hook", <-transport.paths)
+
+	timer.fire()
+	p.Stop()
+}
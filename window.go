@@ -0,0 +1,65 @@
+package profiler
+
+import "time"
+
+// CloseReason is the reason the pprof endpoint was closed
+type CloseReason string
+
+// Reasons of WindowStats
+const (
+	CloseTimeout CloseReason = "timeout"
+	CloseDrained CloseReason = "drained"
+	CloseStopped CloseReason = "stopped"
+	CloseFailed  CloseReason = "failed"
+	CloseLimit   CloseReason = "limit"
+)
+
+// WindowStats summarizes an activation of the pprof endpoint
+type WindowStats struct {
+	// Duration is the time from the activation until the pprof endpoint was shutdown
+	Duration time.Duration
+	// Requests is the number of requests served
+	Requests int64
+	// BytesServed is the number of bytes written to the responses
+	BytesServed int64
+	// Reason is the reason the pprof endpoint was closed
+	Reason CloseReason
+	// ActivationReason is the reason set with TriggerReason, empty for other activations
+	ActivationReason string
+}
+
+// WithOnWindowClose sets a callback which receives the WindowStats after every activation,
+// when the pprof endpoint is shutdown and the PostShutdown hooks are executed
+func WithOnWindowClose(f func(WindowStats)) Opt {
+	return func(p *Profiler) {
+		p.onWindowClose = f
+	}
+}
+
+// windowStart records the start of an activation
+type windowStart struct {
+	at      time.Time
+	metrics Metrics
+}
+
+// startWindowStats records the start of an activation for the WindowStats
+func (p *Profiler) startWindowStats() windowStart {
+	return windowStart{at: p.clock.Now(), metrics: p.Metrics()}
+}
+
+// reportWindowStats passes the WindowStats of the activation to the callback
+func (p *Profiler) reportWindowStats(start windowStart, reason CloseReason) {
+	if p.onWindowClose == nil {
+		return
+	}
+
+	m := p.Metrics()
+
+	p.onWindowClose(WindowStats{
+		Duration:         p.clock.Now().Sub(start.at),
+		Requests:         m.Requests - start.metrics.Requests,
+		BytesServed:      m.BytesServed - start.metrics.BytesServed,
+		Reason:           reason,
+		ActivationReason: p.reason(),
+	})
+}
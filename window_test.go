@@ -0,0 +1,54 @@
+package profiler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithOnWindowClose(t *testing.T) {
+	stats := make(chan WindowStats, 1)
+	c := newFakeClock()
+	p := New(
+		WithSignal(testSignal),
+		WithAddress(freeAddress(t)),
+		WithClock(c),
+		WithOnWindowClose(func(s WindowStats) {
+			stats <- s
+		}),
+	)
+
+	timer := openWindow(t, p, c)
+
+	var n int64
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get("http://" + p.Address() + "/debug/pprof/cmdline")
+		require.NoError(t, err)
+
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		_ = resp.Body.Close()
+		n += int64(len(b))
+	}
+
+	timer.fire()
+
+	s := <-stats
+	assert.Equal(t, CloseTimeout, s.Reason)
+	assert.Equal(t, int64(2), s.Requests)
+	assert.Equal(t, n, s.BytesServed)
+
+	// the stats of the next activation start from zero
+	openWindow(t, p, c)
+	p.Stop()
+
+	s = <-stats
+	assert.Equal(t, CloseStopped, s.Reason)
+	assert.Equal(t, int64(0), s.Requests)
+	assert.Equal(t, int64(0), s.BytesServed)
+}
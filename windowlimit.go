@@ -0,0 +1,54 @@
+package profiler
+
+import "sync"
+
+// nolint: gochecknoglobals
+// openWindows limits the number of open windows of all Profilers of the process
+var openWindows = &windowLimit{}
+
+// windowLimit counts the open windows of the process
+type windowLimit struct {
+	sync.Mutex
+	max  int
+	open int
+}
+
+// SetMaxConcurrentWindows limits the number of pprof endpoints open at the same time across all Profilers
+// of the process to n, e.g. if multiple components create their own Profiler for the same signal.
+// Further activations are rejected with a WarnEvent. A limit of zero or less removes the limit (default).
+func SetMaxConcurrentWindows(n int) {
+	openWindows.Lock()
+	defer openWindows.Unlock()
+
+	openWindows.max = n
+}
+
+// acquire reports whether a window may open, release has to be called after the window is closed
+func (l *windowLimit) acquire() bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.max > 0 && l.open >= l.max {
+		return false
+	}
+
+	l.open++
+
+	return true
+}
+
+// release frees the slot of a closed window
+func (l *windowLimit) release() {
+	l.Lock()
+	defer l.Unlock()
+
+	l.open--
+}
+
+// limit returns the maximum number of open windows
+func (l *windowLimit) limit() int {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.max
+}
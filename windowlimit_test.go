@@ -0,0 +1,35 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMaxConcurrentWindows(t *testing.T) {
+	SetMaxConcurrentWindows(1)
+	defer SetMaxConcurrentWindows(0)
+
+	r := &eventRecorder{}
+	first := New(WithAddress(freeAddress(t)))
+	second := New(WithAddress(freeAddress(t)), WithEventHandler(r.handle))
+
+	first.Start()
+	<-first.Started()
+	second.Start()
+	<-second.Started()
+
+	require.NoError(t, first.TriggerAndWait(context.Background()))
+
+	// the window of the first Profiler is open
+	assert.True(t, errors.Is(second.TriggerAndWait(context.Background()), ErrWindowFailed))
+	assert.Equal(t, 1, r.count(MsgWindowRejected))
+
+	first.Stop()
+
+	require.NoError(t, second.TriggerAndWait(context.Background()))
+	second.Stop()
+}
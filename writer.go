@@ -0,0 +1,42 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// The response writers of the middlewares forward the optional interfaces of the underlying writer,
+// e.g. to serve a websocket with WithExtraHandler.
+
+// hijack takes over the connection of the underlying writer, see http.Hijacker
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w)
+	}
+
+	return h.Hijack()
+}
+
+// push initiates an HTTP/2 server push with the underlying writer, see http.Pusher
+func push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	p, ok := w.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return p.Push(target, opts)
+}
+
+// readFrom copies the reader to the underlying writer, with its io.ReaderFrom if implemented
+func readFrom(w http.ResponseWriter, r io.Reader) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+
+	// hide the ReadFrom method of the caller from io.Copy
+	return io.Copy(struct{ io.Writer }{w}, r)
+}
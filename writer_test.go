@@ -0,0 +1,85 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHijackThroughMiddlewares(t *testing.T) {
+	p := New(
+		WithRecover(true),
+		WithAccessLog(true),
+		WithRequestID(""),
+		WithCompression(true),
+		WithSecurityHeaders(true),
+		WithResponseHeaders(http.Header{"X-Test": []string{"1"}}),
+		WithEventHandler(func(EventType, string, ...interface{}) {}),
+		WithExtraHandler("/debug/upgrade", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			defer conn.Close()
+
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\nhello")
+			_ = rw.Flush()
+		})),
+	)
+
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET /debug/upgrade HTTP/1.1\r\nHost: test\r\nAccept-Encoding: gzip\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// the connection belongs to the handler after the upgrade
+	body, err := ioutil.ReadAll(br)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestReadFromThroughMiddlewares(t *testing.T) {
+	var logged []interface{}
+
+	p := New(
+		WithAccessLog(true),
+		WithEventCodeHandler(func(_ EventType, code EventCode, _ string, args ...interface{}) {
+			if code == CodeAccessLog {
+				logged = args
+			}
+		}),
+		WithExtraHandler("/debug/copy", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			// io.Copy uses the ReadFrom method of the writer
+			_, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader("profile"))
+		})),
+	)
+
+	rec := httptest.NewRecorder()
+	p.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/copy", nil))
+	assert.Equal(t, "profile", rec.Body.String())
+	assert.Equal(t, int64(7), p.Metrics().BytesServed)
+	require.NotNil(t, logged)
+	assert.Equal(t, int64(7), logged[7])
+}